@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to choose how the program authenticates ==========
+
+// Ways the program can authenticate with google, chosen by the "auth_method" setting.
+//
+//  - oauthAuth: a user signs in through the browser, with the OAuth client in "credentials/creds.json". This is the default;
+//  - serviceAccountAuth: a service account key, which needs no human and fits CI and cron jobs;
+//  - defaultCredentialsAuth: the Application Default Credentials, like the "GOOGLE_APPLICATION_CREDENTIALS" key or the account of the machine the program runs on;
+//  - tokenSourceAuth: the token source set in "externalTokenSource" by whoever embeds this program.
+const (
+	oauthAuth              = "oauth"
+	serviceAccountAuth     = "service-account"
+	defaultCredentialsAuth = "adc"
+	tokenSourceAuth        = "token-source"
+)
+
+// The token source used by the "token-source" method. Set it before the service is created.
+var externalTokenSource oauth2.TokenSource
+
+// How the program authenticates.
+//
+// CredentialsFile is the OAuth client for "oauth" and the key for "service-account". TokenStore is where the "oauth" token is kept, see "newTokenStore", and TokenFile and TokenKey are the file and the encryption key used by the file stores. Subject is the email of the user that a service account impersonates through domain-wide delegation, and it can only be used with "service-account" and "adc". Headless makes the "oauth" sign in print the link instead of opening the browser, see "getTokenFromWeb".
+type authConfig struct {
+	Method          string
+	CredentialsFile string
+	TokenFile       string
+	TokenStore      string
+	TokenKey        string
+	Subject         string
+	Headless        bool
+	Scopes          []string
+	TokenSource     oauth2.TokenSource
+}
+
+// Reads the authentication settings from the configuration, see "configKeys". The default credentials file depends on the method.
+func getAuthConfig () (authConfig, error) {
+	settings, err := getSettings()
+	if err != nil {
+		return authConfig{}, err
+	}
+
+	config := authConfig{
+		Method: settings.String("auth_method"),
+		CredentialsFile: settings.String("credentials_file"),
+		TokenFile: settings.String("token_file"),
+		TokenStore: settings.String("token_store"),
+		TokenKey: settings.String("token_key"),
+		Subject: settings.String("impersonate_subject"),
+		Headless: settings.Bool("oauth_headless"),
+		Scopes: settings.List("scopes"),
+		TokenSource: externalTokenSource,
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{drive.DriveScope}
+	}
+	if config.CredentialsFile == "" {
+		config.CredentialsFile = defaultCredentialsFile(config.Method)
+	}
+
+	return config, nil
+}
+
+// Returns the credentials file used when none is configured.
+func defaultCredentialsFile (method string) string {
+	if method == serviceAccountAuth {
+		return "credentials/service-account.json"
+	}
+
+	return "credentials/creds.json"
+}
+
+// This function returns the token source of the chosen authentication method. You have to provide the authentication settings, which can be read with "getAuthConfig".
+func newTokenSource (ctx context.Context, config authConfig) (oauth2.TokenSource, error) {
+	if config.Subject != "" && config.Method != serviceAccountAuth && config.Method != defaultCredentialsAuth {
+		return nil, fmt.Errorf("a subject can only be impersonated by the %q and %q methods, not by %q", serviceAccountAuth, defaultCredentialsAuth, config.Method)
+	}
+
+	switch config.Method {
+	case oauthAuth:
+		return oauthTokenSource(ctx, config)
+
+	case serviceAccountAuth:
+		key, err := ioutil.ReadFile(config.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the service account key: %w", err)
+		}
+		jwtConfig, err := google.JWTConfigFromJSON(key, config.Scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the service account key: %w", err)
+		}
+		jwtConfig.Subject = config.Subject
+		return jwtConfig.TokenSource(ctx), nil
+
+	case defaultCredentialsAuth:
+		credentials, err := google.FindDefaultCredentialsWithParams(ctx, google.CredentialsParams{
+			Scopes: config.Scopes,
+			Subject: config.Subject,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to find the application default credentials: %w", err)
+		}
+		return credentials.TokenSource, nil
+
+	case tokenSourceAuth:
+		if config.TokenSource == nil {
+			return nil, fmt.Errorf("the %q method needs a token source, set \"externalTokenSource\" first", tokenSourceAuth)
+		}
+		return config.TokenSource, nil
+	}
+
+	return nil, fmt.Errorf("unknown authentication method %q, use %q, %q, %q or %q", config.Method, oauthAuth, serviceAccountAuth, defaultCredentialsAuth, tokenSourceAuth)
+}
+
+// Returns the token source of a user signed in through the browser. The token is read from the token store, and the sign in only happens when there is no token yet. Every refreshed token is saved back to the store.
+// 
+// A store that cannot be read is reported, instead of signing in again over it.
+func oauthTokenSource (ctx context.Context, config authConfig) (oauth2.TokenSource, error) {
+	b, err := ioutil.ReadFile(config.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret file: %w", err)
+	}
+
+	// If modifying these scopes, delete your previously saved token.json.
+	oauthConfig, err := google.ConfigFromJSON(b, config.Scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config: %w", err)
+	}
+
+	store, err := newTokenStore(config)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := store.Load()
+	if errors.Is(err, errTokenNotFound) {
+		tok, err = getTokenFromWeb(oauthConfig, config.Headless)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.Save(tok); err != nil {
+			return nil, fmt.Errorf("unable to cache oauth token: %w", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return newPersistingTokenSource(oauthConfig.TokenSource(ctx, tok), store, tok), nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to keep a local copy of the drive metadata ==========
+
+// Where the metadata cache is saved, next to the token.
+const metadataCachePath = "credentials/cache.json"
+
+// Returns the path of the metadata cache of a profile, like "credentials/cache-<name>.json", so the accounts never read each other's listings. Without a profile, the path is "metadataCachePath".
+func profileCachePath (profile string) string {
+	if profile == "" {
+		return metadataCachePath
+	}
+
+	return fmt.Sprintf("credentials/cache-%s.json", profile)
+}
+
+// Fields of the files stored in the cache.
+const cachedFileFields = "id, name, mimeType, parents, size, md5Checksum, modifiedTime, createdTime, trashed"
+
+// Fields requested from the changes feed to keep the cache fresh.
+const cacheChangeFields = "nextPageToken, newStartPageToken, changes(fileId, removed, file(" + cachedFileFields + "))"
+
+// The metadata of a file, as stored in the cache.
+type cachedFile struct {
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	MimeType     string   `json:"mimeType"`
+	Parents      []string `json:"parents,omitempty"`
+	Size         int64    `json:"size,omitempty"`
+	Md5Checksum  string   `json:"md5Checksum,omitempty"`
+	ModifiedTime string   `json:"modifiedTime,omitempty"`
+	CreatedTime  string   `json:"createdTime,omitempty"`
+}
+
+// What is saved in the cache file. Folders holds the folders whose whole content was read, only those can be listed from the cache.
+type cacheState struct {
+	PageToken string                 `json:"pageToken"`
+	RootId    string                 `json:"rootId,omitempty"`
+	Folders   map[string]bool        `json:"folders"`
+	Files     map[string]*cachedFile `json:"files"`
+}
+
+// A local copy of the metadata of the folders already listed. Folders are read from drive the first time they are listed, and after that only the changes feed is read to keep them up to date.
+//
+// Please note that the cache is brought up to date when it is opened. Changes made by other programs while this one runs are only seen the next time the cache is opened, but the files created by this program are added right away.
+type metadataCache struct {
+	mutex  sync.Mutex
+	client *driveClient
+	path   string
+	state  cacheState
+}
+
+// This function opens the metadata cache saved at the path, and reads every change made since it was saved. You have to provide the path of the cache file and whether it should be rebuilt.
+//
+// When "refresh" is true, or the file does not exist yet, the cache starts over and every folder it knew is read again from drive. When drive cannot be reached, the error is printed and the saved metadata is used as it is, so listings keep working offline.
+//
+// The cache is only used by the client once it is set as its "Cache". Each account needs its own cache file, see "profileCachePath".
+func (client *driveClient) openMetadataCache (path string, refresh bool) (*metadataCache, error) {
+	cache := &metadataCache{client: client, path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cache.state); err != nil {
+			return nil, fmt.Errorf("the metadata cache %q is broken, run again with -refresh: %w", path, err)
+		}
+	}
+	if cache.state.Folders == nil || cache.state.Files == nil {
+		cache.state.PageToken = ""
+	}
+
+	if refresh || cache.state.PageToken == "" {
+		return cache, cache.rebuild()
+	}
+
+	if err := cache.update(context.Background()); err != nil {
+		errorPrinter(fmt.Errorf("the metadata cache could not be updated, using the saved copy: %w", err))
+	}
+
+	return cache, nil
+}
+
+// Forgets every file and reads again the folders that were cached.
+func (cache *metadataCache) rebuild () error {
+	// The token is taken before reading the folders, so nothing that changes while they are read is missed.
+	startToken, err := cache.client.Service.Changes.GetStartPageToken().SupportsAllDrives(true).Do()
+	if err != nil {
+		return err
+	}
+
+	folders := cache.state.Folders
+	cache.state = cacheState{
+		PageToken: startToken.StartPageToken,
+		RootId: cache.state.RootId,
+		Folders: make(map[string]bool),
+		Files: make(map[string]*cachedFile),
+	}
+
+	for folderId := range folders {
+		if err := cache.readFolder(folderId); err != nil {
+			return err
+		}
+	}
+
+	return cache.save()
+}
+
+// Reads every change made since the last update and applies it to the cached files.
+func (cache *metadataCache) update (ctx context.Context) error {
+	for {
+		changeList, err := cache.client.Service.Changes.List(cache.state.PageToken).
+			Fields(cacheChangeFields).
+			IncludeItemsFromAllDrives(true).
+			SupportsAllDrives(true).
+			IncludeRemoved(true).
+			PageSize(1000).
+			Context(ctx).
+			Do()
+		if err != nil {
+			return err
+		}
+
+		cache.mutex.Lock()
+		for _, change := range changeList.Changes {
+			cache.apply(change)
+		}
+		if changeList.NextPageToken != "" {
+			cache.state.PageToken = changeList.NextPageToken
+		} else {
+			cache.state.PageToken = changeList.NewStartPageToken
+		}
+		cache.mutex.Unlock()
+
+		if changeList.NextPageToken == "" {
+			return cache.save()
+		}
+	}
+}
+
+// Applies a single change. Only files that are already cached, or that are inside a cached folder, are stored.
+func (cache *metadataCache) apply (change *drive.Change) {
+	if change.Removed || change.File == nil || change.File.Trashed {
+		delete(cache.state.Files, change.FileId)
+		delete(cache.state.Folders, change.FileId)
+		return
+	}
+
+	_, known := cache.state.Files[change.FileId]
+	if !known && !cache.isListed(change.File.Parents) {
+		return
+	}
+
+	cache.state.Files[change.FileId] = newCachedFile(change.File)
+}
+
+// Checks if any of the parents is a folder whose content is cached.
+func (cache *metadataCache) isListed (parents []string) bool {
+	for _, parent := range parents {
+		if cache.state.Folders[parent] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads the whole content of a folder from drive and stores it.
+func (cache *metadataCache) readFolder (folderId string) error {
+	var files []*drive.File
+
+	iterator := cache.client.listFolder(context.Background(), folderId, listOptions{PageSize: 1000, Fields: cachedFileFields})
+	for iterator.Next() {
+		files = append(files, iterator.File())
+	}
+	if err := iterator.Err(); err != nil {
+		return err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for _, file := range files {
+		cache.state.Files[file.Id] = newCachedFile(file)
+	}
+	cache.state.Folders[folderId] = true
+
+	return nil
+}
+
+// Saves the cache. The data is written to a temporary file first, so a crash never leaves a broken cache behind.
+func (cache *metadataCache) save () error {
+	cache.mutex.Lock()
+	data, err := json.Marshal(cache.state)
+	cache.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cache.path), 0700); err != nil {
+		return err
+	}
+
+	temporaryPath := cache.path + ".tmp"
+	if err := os.WriteFile(temporaryPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, cache.path)
+}
+
+// Returns the files inside a folder. The folder is read from drive and saved the first time, every other time the files come from the cache.
+//
+// Every file has the fields in "cachedFileFields", whatever fields the caller wanted.
+func (cache *metadataCache) children (folderId string) ([]*drive.File, error) {
+	if folderId == "root" {
+		rootId, err := cache.rootId()
+		if err != nil {
+			return nil, err
+		}
+		folderId = rootId
+	}
+
+	cache.mutex.Lock()
+	listed := cache.state.Folders[folderId]
+	cache.mutex.Unlock()
+
+	if !listed {
+		if err := cache.readFolder(folderId); err != nil {
+			return nil, err
+		}
+		if err := cache.save(); err != nil {
+			errorPrinter(err)
+		}
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	var files []*drive.File
+	for _, file := range cache.state.Files {
+		for _, parent := range file.Parents {
+			if parent == folderId {
+				files = append(files, file.toDriveFile())
+				break
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// Returns the real ID of My Drive. Drive files list it as their parent, never the "root" alias.
+func (cache *metadataCache) rootId () (string, error) {
+	cache.mutex.Lock()
+	rootId := cache.state.RootId
+	cache.mutex.Unlock()
+	if rootId != "" {
+		return rootId, nil
+	}
+
+	root, err := cache.client.Service.Files.Get("root").Fields("id").Do()
+	if err != nil {
+		return "", err
+	}
+
+	cache.mutex.Lock()
+	cache.state.RootId = root.Id
+	cache.mutex.Unlock()
+
+	return root.Id, nil
+}
+
+// Stores a file created or changed by this program, so it is found before the next update. Files that are not inside a cached folder anymore, like the ones moved away, are removed. Nothing happens when the cache is disabled.
+func (cache *metadataCache) remember (file *drive.File) {
+	if cache == nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	cached := newCachedFile(file)
+	cached.Parents = nil
+	for _, parent := range file.Parents {
+		if parent == "root" && cache.state.RootId != "" {
+			parent = cache.state.RootId
+		}
+		cached.Parents = append(cached.Parents, parent)
+	}
+
+	if cache.isListed(cached.Parents) {
+		cache.state.Files[file.Id] = cached
+	} else {
+		delete(cache.state.Files, file.Id)
+	}
+	cache.mutex.Unlock()
+
+	errorPrinter(cache.save())
+}
+
+// Removes a file deleted or trashed by this program. Nothing happens when the cache is disabled.
+func (cache *metadataCache) forget (fileId string) {
+	if cache == nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	delete(cache.state.Files, fileId)
+	delete(cache.state.Folders, fileId)
+	cache.mutex.Unlock()
+
+	errorPrinter(cache.save())
+}
+
+// Copies the cached fields of a drive file.
+func newCachedFile (file *drive.File) *cachedFile {
+	return &cachedFile{
+		Id: file.Id,
+		Name: file.Name,
+		MimeType: file.MimeType,
+		Parents: file.Parents,
+		Size: file.Size,
+		Md5Checksum: file.Md5Checksum,
+		ModifiedTime: file.ModifiedTime,
+		CreatedTime: file.CreatedTime,
+	}
+}
+
+// Turns a cached file back into a drive file.
+func (file *cachedFile) toDriveFile () *drive.File {
+	return &drive.File{
+		Id: file.Id,
+		Name: file.Name,
+		MimeType: file.MimeType,
+		Parents: file.Parents,
+		Size: file.Size,
+		Md5Checksum: file.Md5Checksum,
+		ModifiedTime: file.ModifiedTime,
+		CreatedTime: file.CreatedTime,
+	}
+}
@@ -0,0 +1,900 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible for the command-line interface ==========
+
+// Exit codes of the command-line interface.
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// Returned by a command when its arguments are wrong. It makes the program exit with "exitUsage" instead of "exitFailure".
+type usageError struct {
+	message string
+}
+
+func (err *usageError) Error () string {
+	return err.message
+}
+
+// A subcommand of the command-line interface.
+//
+// Setup registers the flags of the command and returns the function that runs it with the remaining arguments. The number of arguments is checked before running, and MaxArgs -1 means that there is no limit. Commands with SkipAuth run without signing in.
+type cliCommand struct {
+	Name        string
+	Usage       string
+	Description string
+	MinArgs     int
+	MaxArgs     int
+	SkipAuth    bool
+	Setup       func(flags *flag.FlagSet) func(args []string) error
+}
+
+// When true, the commands print JSON to the standard output and every other message goes to the standard error.
+var jsonOutput bool
+
+// Where "prettyPrinter" and "errorPrinter" write to. It is changed to the standard error when JSON is printed, so the output can be parsed by scripts.
+var messageOutput io.Writer = os.Stdout
+
+// The result of a command on a single item, used by the commands that accept many files.
+type itemResult struct {
+	Id    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Every subcommand, in the order they are shown in the help.
+var cliCommands = []cliCommand{
+	{Name: "ls", Usage: "[flags] [folder]", Description: "lists the files inside a folder, or inside the trash", MinArgs: 0, MaxArgs: 1, Setup: setupLs},
+	{Name: "mkdir", Usage: "[flags] <name> <parent folder>", Description: "creates a folder", MinArgs: 2, MaxArgs: 2, Setup: setupMkdir},
+	{Name: "cp", Usage: "[flags] <file>... <target folder>", Description: "copies files to a folder", MinArgs: 2, MaxArgs: -1, Setup: setupCp},
+	{Name: "mv", Usage: "[flags] <file>... <target folder>", Description: "moves files to a folder", MinArgs: 2, MaxArgs: -1, Setup: setupMv},
+	{Name: "upload", Usage: "[flags] <local file>... <target folder>", Description: "uploads local files", MinArgs: 2, MaxArgs: -1, Setup: setupUpload},
+	{Name: "download", Usage: "[flags] <file or folder> <local folder>", Description: "downloads a file, or a whole folder", MinArgs: 2, MaxArgs: 2, Setup: setupDownload},
+	{Name: "sync", Usage: "[flags] <local folder> <target folder>", Description: "mirrors a local folder inside a drive folder", MinArgs: 2, MaxArgs: 2, Setup: setupSync},
+	{Name: "rm", Usage: "[flags] <file>...", Description: "moves files to the trash, or deletes them permanently", MinArgs: 1, MaxArgs: -1, Setup: setupRm},
+	{Name: "trash", Usage: "[flags] <file>...", Description: "moves files to the trash", MinArgs: 1, MaxArgs: -1, Setup: setupTrash},
+	{Name: "restore", Usage: "[flags] <file>...", Description: "brings files back from the trash", MinArgs: 1, MaxArgs: -1, Setup: setupRestore},
+	{Name: "empty-trash", Usage: "[flags]", Description: "permanently deletes the files inside the trash", MinArgs: 0, MaxArgs: 0, Setup: setupEmptyTrash},
+	{Name: "dupes", Usage: "[flags] <folder>", Description: "finds files with the same content inside a folder tree, and optionally trashes the extra copies", MinArgs: 1, MaxArgs: 1, Setup: setupDupes},
+	{Name: "watch", Usage: "[flags] <folder>", Description: "prints the files created, modified, moved, trashed or deleted inside a folder tree", MinArgs: 1, MaxArgs: 1, Setup: setupWatch},
+	{Name: "drives", Usage: "[flags] [name]", Description: "lists the shared drives, or finds one by its name", MinArgs: 0, MaxArgs: 1, Setup: setupDrives},
+	{Name: "share", Usage: "[flags] <file or folder> [email or domain]", Description: "grants a role on a file or folder", MinArgs: 1, MaxArgs: 2, Setup: setupShare},
+	{Name: "push", Usage: "[flags] [file]...", Description: "receives the notifications of drive about files, or about the whole drive when no file is given", MinArgs: 0, MaxArgs: -1, Setup: setupPush},
+	{Name: "config", Usage: "[flags]", Description: "prints the effective configuration and where each value comes from", MinArgs: 0, MaxArgs: 0, SkipAuth: true, Setup: setupConfig},
+}
+
+// Prints the list of subcommands.
+func printCliUsage (output io.Writer) {
+	fmt.Fprintf(output, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", os.Args[0])
+	for _, command := range cliCommands {
+		fmt.Fprintf(output, "  %-12s %s\n", command.Name, command.Description)
+	}
+	fmt.Fprintf(output, "\nFiles and folders can be given as drive URLs, IDs or paths like /Reports/2024/grades.pdf. Run \"%s <command> -h\" to see the flags of a command.\n", os.Args[0])
+}
+
+// Runs the command-line interface with the given arguments, without the program name, and returns the exit code.
+func runCli (args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printCliUsage(os.Stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitSuccess
+	}
+
+	var command *cliCommand
+	for index := range cliCommands {
+		if cliCommands[index].Name == args[0] {
+			command = &cliCommands[index]
+		}
+	}
+	if command == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		printCliUsage(os.Stderr)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	flags.BoolVar(&jsonOutput, "json", false, "print the result as JSON")
+	flags.String("root", "root", "folder URL or ID that paths like /Reports/2024 start from")
+	flags.String("profile", "", "account profile to use, from "+profilesPath)
+	flags.Bool("cache", false, "read listings and duplicate checks from the local metadata cache")
+	refresh := flags.Bool("refresh", false, "rebuild the local metadata cache from drive, implies -cache")
+	configPath := flags.String("config", "", "YAML or TOML config file, the default is config.yaml, config.yml or config.toml in the working directory or in the user config folder")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s %s\n\n%s.\n\nFlags:\n", os.Args[0], command.Name, command.Usage, strings.ToUpper(command.Description[:1])+command.Description[1:])
+		flags.PrintDefaults()
+	}
+	run := command.Setup(flags)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitUsage
+	}
+
+	arguments := flags.Args()
+	if len(arguments) < command.MinArgs || (command.MaxArgs >= 0 && len(arguments) > command.MaxArgs) {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if jsonOutput {
+		messageOutput = os.Stderr
+	}
+
+	loaded, err := loadConfig(configKeys, flags, *configPath)
+	settings = loaded
+	if err != nil {
+		// The effective configuration is still printed, it shows where the invalid values came from.
+		if command.Name == "config" {
+			run(arguments)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return exitUsage
+	}
+	requestLimiter.setRate(float64(settings.Int("requests_per_second")))
+
+	if command.SkipAuth {
+		return exitCode(run(arguments), flags)
+	}
+
+	defaultClient = getDefaultClient()
+
+	if settings.Bool("cache") || *refresh {
+		cache, err := defaultClient.openMetadataCache(profileCachePath(defaultClient.Profile), *refresh)
+		if err != nil {
+			errorPrinter(err)
+			return exitFailure
+		}
+		defaultClient.Cache = cache
+	}
+
+	return exitCode(run(arguments), flags)
+}
+
+// Turns the error of a command into the exit code, printing it first.
+func exitCode (err error, flags *flag.FlagSet) int {
+	if err == nil {
+		return exitSuccess
+	}
+
+	var usage *usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintf(os.Stderr, "%s\n\n", err)
+		flags.Usage()
+		return exitUsage
+	}
+
+	errorPrinter(err)
+	return exitFailure
+}
+
+// Prints a value as indented JSON to the standard output.
+func printJson (value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// Prints a drive file, as JSON or as a line with its name, ID and type.
+func printFile (file *drive.File) error {
+	if jsonOutput {
+		return printJson(file)
+	}
+
+	fmt.Printf("%s\t%s\t%s\n", file.Name, file.Id, file.MimeType)
+	return nil
+}
+
+// Prints the results of a command that runs on many items, and returns an error when any item failed.
+func printItemResults (results []itemResult) error {
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	if jsonOutput {
+		if err := printJson(results); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			if result.Error != "" {
+				fmt.Printf("%s\tfailed: %s\n", result.Id, result.Error)
+			} else {
+				fmt.Printf("%s\t%s\tok\n", result.Id, result.Name)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d items failed", failed, len(results))
+	}
+	return nil
+}
+
+// Runs an operation on every file given and collects the results, so a single failure does not stop the others.
+func runOnEachFile (fileUrls []string, operation func(fileId string) (*drive.File, error)) error {
+	var results []itemResult
+
+	for _, fileUrl := range fileUrls {
+		fileId, err := defaultClient.getFolderId(fileUrl)
+		result := itemResult{Id: fileId}
+		if err != nil {
+			result.Id = fileUrl
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		file, err := operation(result.Id)
+		if err != nil {
+			result.Error = err.Error()
+		} else if file != nil {
+			result.Name = file.Name
+		}
+
+		results = append(results, result)
+	}
+
+	return printItemResults(results)
+}
+
+// Fetches the metadata of a file given as URL or ID.
+func (client *driveClient) getFileInfo (fileUrl string) (*drive.File, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Service.Files.Get(fileId).
+		Fields("id, name, mimeType, parents, size, md5Checksum, modifiedTime").
+		SupportsAllDrives(true).
+		Do()
+}
+
+func setupLs (flags *flag.FlagSet) func(args []string) error {
+	pageSize := flags.Int64("page-size", 0, "how many files are fetched per request")
+	trashed := flags.Bool("trashed", false, "list the trash instead of a folder")
+
+	return func(args []string) error {
+		if *trashed {
+			filter := trashFilter{}
+			if len(args) == 1 {
+				filter.FolderId = args[0]
+			}
+
+			files, err := defaultClient.listTrash(filter)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJson(files)
+			}
+			for _, file := range files {
+				printFile(file)
+			}
+			return nil
+		}
+
+		if len(args) == 0 {
+			if defaultClient.DefaultFolder == "" {
+				return &usageError{"a folder is required, unless --trashed is used or the profile has a default folder"}
+			}
+			args = []string{defaultClient.DefaultFolder}
+		}
+
+		if defaultClient.Cache != nil {
+			files, err := defaultClient.getFolderChildren(args[0], "")
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				if files == nil {
+					files = []*drive.File{}
+				}
+				return printJson(files)
+			}
+			for _, file := range files {
+				printFile(file)
+			}
+			return nil
+		}
+
+		iterator := defaultClient.listFolder(context.Background(), args[0], listOptions{
+			PageSize: *pageSize,
+			Fields: "id, name, mimeType, size, modifiedTime",
+		})
+
+		// The JSON list is written item by item, so big folders are never held in memory.
+		count := 0
+		for iterator.Next() {
+			if jsonOutput {
+				separator := ","
+				if count == 0 {
+					separator = "["
+				}
+				data, err := json.Marshal(iterator.File())
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s\n  %s", separator, data)
+			} else {
+				printFile(iterator.File())
+			}
+			count++
+		}
+		if jsonOutput {
+			if count == 0 {
+				fmt.Print("[")
+			}
+			fmt.Print("\n]\n")
+		}
+
+		return iterator.Err()
+	}
+}
+
+func setupMkdir (flags *flag.FlagSet) func(args []string) error {
+	force := flags.Bool("force", false, "create the folder even if there is already one with the same name")
+	parents := flags.Bool("parents", false, "create the missing folders of a parent path")
+
+	return func(args []string) error {
+		parent := args[1]
+		if *parents && isDrivePath(parent) {
+			parentId, err := defaultClient.ensureFolderPath(parent)
+			if err != nil {
+				return err
+			}
+			parent = parentId
+		}
+
+		folder, err := defaultClient.createFolder(args[0], parent, *force)
+		if err != nil {
+			return err
+		}
+		folder.Name = args[0]
+		folder.MimeType = "application/vnd.google-apps.folder"
+
+		return printFile(folder)
+	}
+}
+
+// Runs the transfers in a transfer queue and prints the result of each one, followed by the numbers of the queue.
+func runTransfers (jobs []transferJob, workers int) error {
+	queue := defaultClient.newTransferQueue(workers)
+	go func() {
+		for _, job := range jobs {
+			queue.Submit(job)
+		}
+		queue.Close()
+	}()
+
+	results := []itemResult{}
+	for result := range queue.Results() {
+		item := itemResult{Id: result.Job.Source}
+		if result.Job.File != nil {
+			item.Id = result.Job.File.Id
+		}
+		if result.Err != nil {
+			item.Error = result.Err.Error()
+		} else if result.File != nil {
+			item.Name = result.File.Name
+		}
+		results = append(results, item)
+	}
+
+	err := printItemResults(results)
+	if !jsonOutput {
+		queue.Stats().print()
+	}
+	return err
+}
+
+// Reads the files of a command that runs on many drive files, turning each one into a transfer job. Files that cannot be read fail the whole command, before any transfer starts.
+func transferJobsOf (fileUrls []string, job transferJob) ([]transferJob, error) {
+	var jobs []transferJob
+	for _, fileUrl := range fileUrls {
+		file, err := defaultClient.getFileInfo(fileUrl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileUrl, err)
+		}
+		job.File = file
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func setupCp (flags *flag.FlagSet) func(args []string) error {
+	force := flags.Bool("force", false, "copy the file even if there is already one with the same name and type")
+	workers := flags.Int("workers", defaultTransferWorkers, "how many files are copied at the same time")
+
+	return func(args []string) error {
+		if len(args) > 2 {
+			jobs, err := transferJobsOf(args[:len(args)-1], transferJob{Kind: copyTransfer, Target: args[len(args)-1], Force: *force})
+			if err != nil {
+				return err
+			}
+			return runTransfers(jobs, *workers)
+		}
+
+		file, err := defaultClient.getFileInfo(args[0])
+		if err != nil {
+			return err
+		}
+
+		targetId, err := defaultClient.getFolderId(args[1])
+		if err != nil {
+			return err
+		}
+
+		copiedFile, err := defaultClient.copyFileTo(file, targetId, *force)
+		if err != nil {
+			return err
+		}
+
+		return printFile(copiedFile)
+	}
+}
+
+func setupMv (flags *flag.FlagSet) func(args []string) error {
+	workers := flags.Int("workers", defaultTransferWorkers, "how many files are moved at the same time")
+
+	return func(args []string) error {
+		if len(args) > 2 {
+			jobs, err := transferJobsOf(args[:len(args)-1], transferJob{Kind: moveTransfer, Target: args[len(args)-1]})
+			if err != nil {
+				return err
+			}
+			return runTransfers(jobs, *workers)
+		}
+
+		file, err := defaultClient.getFileInfo(args[0])
+		if err != nil {
+			return err
+		}
+
+		movedFile, err := defaultClient.moveFileTo("", args[1], file)
+		if err != nil {
+			return err
+		}
+
+		return printFile(movedFile)
+	}
+}
+
+func setupUpload (flags *flag.FlagSet) func(args []string) error {
+	resumable := flags.Bool("resumable", false, "upload in chunks, even if the file is small")
+	chunkSize := flags.Int64("chunk-size", defaultUploadChunkSize, "size of each chunk of a resumable upload, in bytes")
+	progress := flags.Bool("progress", false, "print the progress of resumable uploads")
+	workers := flags.Int("workers", defaultTransferWorkers, "how many files are uploaded at the same time, when many are given")
+
+	return func(args []string) error {
+		// Many files are uploaded at the same time, each one in a single request.
+		if len(args) > 2 {
+			var jobs []transferJob
+			for _, localPath := range args[:len(args)-1] {
+				jobs = append(jobs, transferJob{Kind: uploadTransfer, Source: localPath, Target: args[len(args)-1]})
+			}
+			return runTransfers(jobs, *workers)
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fileInfo, err := file.Stat()
+		if err != nil {
+			return err
+		}
+
+		var uploadedFile *drive.File
+		if *resumable || fileInfo.Size() > resumableUploadThreshold {
+			options := resumableUploadOptions{ChunkSize: *chunkSize}
+			if *progress {
+				options.Progress = progressPrinter
+			}
+			uploadedFile, err = defaultClient.uploadFilesResumable(file, args[1], options)
+		} else {
+			uploadedFile, err = defaultClient.uploadFiles(file, args[1])
+		}
+		if err != nil {
+			return err
+		}
+
+		return printFile(uploadedFile)
+	}
+}
+
+func setupDownload (flags *flag.FlagSet) func(args []string) error {
+	format := flags.String("format", "", "format of exported google workspace files, like pdf, docx or xlsx")
+
+	return func(args []string) error {
+		file, err := defaultClient.getFileInfo(args[0])
+		if err != nil {
+			return err
+		}
+
+		if file.MimeType == "application/vnd.google-apps.folder" {
+			failures, err := defaultClient.downloadFolder(file.Id, args[1])
+			if err != nil {
+				return err
+			}
+
+			results := []itemResult{}
+			for _, failure := range failures {
+				results = append(results, itemResult{Id: failure.Path, Error: failure.Err.Error()})
+			}
+			if len(results) == 0 && !jsonOutput {
+				prettyPrinter("Every file was downloaded!")
+				return nil
+			}
+			return printItemResults(results)
+		}
+
+		fileFormat := *format
+		if fileFormat == "" {
+			fileFormat = defaultExportFormat(file)
+		}
+		if err := defaultClient.downloadFiles(file, args[1], fileFormat); err != nil {
+			return err
+		}
+
+		return printFile(file)
+	}
+}
+
+func setupSync (flags *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		summary, err := defaultClient.syncFolder(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			failed := make(map[string]string)
+			for path, err := range summary.Failed {
+				failed[path] = err.Error()
+			}
+			err = printJson(map[string]interface{}{
+				"created": summary.Created,
+				"updated": summary.Updated,
+				"skipped": summary.Skipped,
+				"failed": failed,
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			summary.print()
+		}
+
+		if len(summary.Failed) > 0 {
+			return fmt.Errorf("%d items could not be synced", len(summary.Failed))
+		}
+		return nil
+	}
+}
+
+func setupRm (flags *flag.FlagSet) func(args []string) error {
+	permanent := flags.Bool("permanent", false, "delete the files permanently instead of moving them to the trash")
+
+	return func(args []string) error {
+		return runOnEachFile(args, func(fileId string) (*drive.File, error) {
+			return nil, defaultClient.deleteFile(fileId, *permanent)
+		})
+	}
+}
+
+func setupTrash (flags *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		return runOnEachFile(args, defaultClient.trashFile)
+	}
+}
+
+func setupRestore (flags *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		return runOnEachFile(args, defaultClient.restoreFile)
+	}
+}
+
+func setupEmptyTrash (flags *flag.FlagSet) func(args []string) error {
+	olderThan := flags.Duration("older-than", 0, "only delete files trashed longer ago than that, like 720h")
+	folder := flags.String("folder", "", "only delete files that were inside this folder")
+	name := flags.String("name", "", "only delete files whose name matches this pattern, like *captura*")
+	dryRun := flags.Bool("dry-run", false, "only print the files that would be deleted")
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+
+	return func(args []string) error {
+		options := emptyTrashOptions{
+			Filter: trashFilter{OlderThan: *olderThan, FolderId: *folder, NamePattern: *name},
+			DryRun: *dryRun,
+		}
+		if !*yes {
+			options.Confirm = confirmInTerminal
+		}
+
+		files, err := defaultClient.emptyTrash(options)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			if files == nil {
+				files = []*drive.File{}
+			}
+			return printJson(files)
+		}
+		for _, file := range files {
+			printFile(file)
+		}
+		return nil
+	}
+}
+
+func setupShare (flags *flag.FlagSet) func(args []string) error {
+	role := flags.String("role", readerRole, "role to grant: reader, commenter, writer or organizer")
+	granteeType := flags.String("type", userGrantee, "who receives the role: user, group, domain or anyone")
+	notify := flags.Bool("notify", true, "send the notification email to users and groups")
+	message := flags.String("message", "", "message added to the notification email")
+	recursive := flags.Bool("recursive", false, "also share every file and subfolder, one by one")
+
+	return func(args []string) error {
+		who := grantee{Type: *granteeType}
+		if len(args) == 2 {
+			who.Address = args[1]
+		}
+		options := shareOptions{SuppressNotification: !*notify, Message: *message}
+
+		if _, err := newPermission(who, *role); err != nil {
+			return &usageError{err.Error()}
+		}
+
+		if *recursive {
+			results := []itemResult{}
+			for id, err := range defaultClient.shareFolderTree(args[0], who, *role, options) {
+				results = append(results, itemResult{Id: id, Error: err.Error()})
+			}
+			if len(results) == 0 && !jsonOutput {
+				prettyPrinter("Every file was shared!")
+				return nil
+			}
+			return printItemResults(results)
+		}
+
+		permission, err := defaultClient.shareFile(args[0], who, *role, options)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			return printJson(permission)
+		}
+		fmt.Printf("%s\t%s\t%s%s\n", permission.Id, permission.Role, permission.EmailAddress, permission.Domain)
+		return nil
+	}
+}
+
+func setupDupes (flags *flag.FlagSet) func(args []string) error {
+	dedupe := flags.Bool("dedupe", false, "keep one copy of each group and move the others to the trash")
+	keepIn := flags.String("keep-in", "", "keep the copy inside this folder instead of the oldest one")
+	dryRun := flags.Bool("dry-run", false, "only print the copies that would be trashed")
+
+	return func(args []string) error {
+		groups, err := defaultClient.findContentDuplicates(args[0])
+		if err != nil {
+			return err
+		}
+
+		if !*dedupe && !*dryRun {
+			if jsonOutput {
+				if groups == nil {
+					groups = []duplicateGroup{}
+				}
+				return printJson(groups)
+			}
+			printDuplicateReport(groups)
+			return nil
+		}
+
+		trashed, err := defaultClient.dedupeFiles(groups, dedupeOptions{PreferredFolder: *keepIn, DryRun: *dryRun})
+		if jsonOutput {
+			if trashed == nil {
+				trashed = []*drive.File{}
+			}
+			if printErr := printJson(trashed); printErr != nil {
+				return printErr
+			}
+		} else {
+			for _, file := range trashed {
+				printFile(file)
+			}
+		}
+
+		return err
+	}
+}
+
+func setupPush (flags *flag.FlagSet) func(args []string) error {
+	flags.String("listen", "", "local address where the notifications are received, the default is 127.0.0.1:8080")
+	flags.String("url", "", "public HTTPS address that reaches the local address, where drive sends the notifications")
+	ttl := flags.Duration("ttl", time.Hour, "how long each channel lives before it is renewed")
+
+	return func(args []string) error {
+		address := settings.String("push_url")
+		if address == "" {
+			return &usageError{"the public address is required, set it with -url or the DRIVE_PUSH_URL variable"}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		var mutex sync.Mutex
+		receiver := defaultClient.newPushReceiver(func(event pushEvent) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if jsonOutput {
+				data, err := json.Marshal(event)
+				if err == nil {
+					fmt.Printf("%s\n", data)
+				}
+				return
+			}
+			fmt.Printf("%s\t%s\t%s\n", event.State, event.ResourceId, strings.Join(event.Changed, ","))
+		})
+		defer func() {
+			errorPrinter(receiver.StopAll())
+		}()
+
+		// The server is started first, because drive sends the "sync" message as soon as a channel is created.
+		served := make(chan error, 1)
+		go func() {
+			served <- receiver.ListenAndServe(ctx, settings.String("push_address"))
+		}()
+
+		var err error
+		if len(args) == 0 {
+			_, err = receiver.WatchChanges(address, *ttl)
+		}
+		for _, fileUrl := range args {
+			if err == nil {
+				_, err = receiver.WatchFile(fileUrl, address, *ttl)
+			}
+		}
+		if err != nil {
+			stop()
+			<-served
+			return err
+		}
+
+		go receiver.KeepRenewed(ctx, *ttl/4)
+
+		prettyPrinter(fmt.Sprintf("Receiving notifications on %s, press Ctrl+C to stop.", settings.String("push_address")))
+		return <-served
+	}
+}
+
+func setupWatch (flags *flag.FlagSet) func(args []string) error {
+	interval := flags.Duration("interval", 30*time.Second, "how often the changes of drive are read")
+	statePath := flags.String("state", "", "file where the position of the watcher is saved, the default is credentials/watch-<folder ID>.json")
+
+	return func(args []string) error {
+		folderId, err := defaultClient.getFolderId(args[0])
+		if err != nil {
+			return err
+		}
+		if *statePath == "" {
+			*statePath = fmt.Sprintf("credentials/watch-%s.json", folderId)
+		}
+
+		watcher, err := defaultClient.newChangesWatcher(folderId, *statePath)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		prettyPrinter(fmt.Sprintf("Watching %s every %s, press Ctrl+C to stop.", args[0], *interval))
+		err = watcher.Watch(ctx, *interval, func(event fileEvent) {
+			if jsonOutput {
+				data, err := json.Marshal(map[string]interface{}{
+					"kind": event.Kind.String(),
+					"fileId": event.FileId,
+					"name": event.Name,
+					"time": event.Time,
+				})
+				if err == nil {
+					fmt.Printf("%s\n", data)
+				}
+				return
+			}
+			fmt.Printf("%s\t%s\t%s\n", event.Kind, event.FileId, event.Name)
+		})
+		// Ctrl+C is the normal way to stop watching, not a failure.
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+}
+
+func setupDrives (flags *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		var drives []*drive.Drive
+		if len(args) == 1 {
+			sharedDrive, err := defaultClient.getSharedDriveByName(args[0])
+			if err != nil {
+				return err
+			}
+			drives = []*drive.Drive{sharedDrive}
+		} else {
+			var err error
+			drives, err = defaultClient.listSharedDrives()
+			if err != nil {
+				return err
+			}
+		}
+
+		if jsonOutput {
+			if drives == nil {
+				drives = []*drive.Drive{}
+			}
+			return printJson(drives)
+		}
+		for _, sharedDrive := range drives {
+			fmt.Printf("%s\t%s\n", sharedDrive.Name, sharedDrive.Id)
+		}
+		return nil
+	}
+}
+
+func setupConfig (flags *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if !jsonOutput {
+			return settings.print(os.Stdout)
+		}
+
+		type entry struct {
+			Name   string `json:"name"`
+			Value  string `json:"value"`
+			Source string `json:"source"`
+		}
+		entries := []entry{}
+		for _, key := range settings.keys {
+			value := settings.String(key.Name)
+			if key.Secret && value != "" {
+				value = "(hidden)"
+			}
+			entries = append(entries, entry{Name: key.Name, Value: value, Source: settings.Source(key.Name)})
+		}
+
+		return printJson(entries)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible for comments and replies ==========
+
+// Fields of the comments returned by the functions below. Drive requires the fields of comments to always be chosen.
+const commentFields = "id, content, createdTime, modifiedTime, resolved, quotedFileContent, author(displayName, emailAddress), replies(id, content, action, createdTime, author(displayName, emailAddress))"
+
+// Fields of the replies returned by the functions below.
+const replyFields = "id, content, action, createdTime, author(displayName, emailAddress)"
+
+// Returns the comments of a file, along with their replies. You have to provide the file URL or ID. When "openOnly" is true, resolved comments are left out.
+func (client *driveClient) listComments (fileUrl string, openOnly bool) ([]*drive.Comment, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []*drive.Comment
+	err = client.Service.Comments.List(fileId).
+		Fields("nextPageToken, comments(" + commentFields + ")").
+		PageSize(100).
+		Pages(context.Background(), func(commentList *drive.CommentList) error {
+			for _, comment := range commentList.Comments {
+				if openOnly && comment.Resolved {
+					continue
+				}
+				comments = append(comments, comment)
+			}
+			return nil
+		})
+
+	return comments, err
+}
+
+// This function posts a new comment on a file. You have to provide the file URL or ID and the content of the comment.
+func (client *driveClient) postComment (fileUrl string, content string) (*drive.Comment, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Service.Comments.Create(fileId, &drive.Comment{Content: content}).Fields(commentFields).Do()
+}
+
+// This function replies to a comment. You have to provide the file URL or ID, the comment ID and the content of the reply.
+func (client *driveClient) replyToComment (fileUrl string, commentId string, content string) (*drive.Reply, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Service.Replies.Create(fileId, commentId, &drive.Reply{Content: content}).Fields(replyFields).Do()
+}
+
+// This function resolves a comment, posting a last reply to it. The content of the reply is optional.
+func (client *driveClient) resolveComment (fileUrl string, commentId string, content string) (*drive.Reply, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Service.Replies.Create(fileId, commentId, &drive.Reply{
+		Action: "resolve",
+		Content: content,
+	}).Fields(replyFields).Do()
+}
+
+// A comment thread in the format used by "exportComments".
+type commentThread struct {
+	FileId      string         `json:"fileId"`
+	FileName    string         `json:"fileName"`
+	CommentId   string         `json:"commentId"`
+	Author      string         `json:"author"`
+	CreatedTime string         `json:"createdTime"`
+	Resolved    bool           `json:"resolved"`
+	QuotedText  string         `json:"quotedText,omitempty"`
+	Content     string         `json:"content"`
+	Replies     []commentReply `json:"replies"`
+}
+
+// A reply in the format used by "exportComments".
+type commentReply struct {
+	ReplyId     string `json:"replyId"`
+	Author      string `json:"author"`
+	CreatedTime string `json:"createdTime"`
+	Action      string `json:"action,omitempty"`
+	Content     string `json:"content"`
+}
+
+// Returns the name and email of an author, like "Pedro <pedro@example.com>".
+func authorName (user *drive.User) string {
+	if user == nil {
+		return ""
+	}
+	if user.EmailAddress == "" {
+		return user.DisplayName
+	}
+
+	return fmt.Sprintf("%s <%s>", user.DisplayName, user.EmailAddress)
+}
+
+// Turns the comments of a file into threads.
+func newCommentThreads (file *drive.File, comments []*drive.Comment) []commentThread {
+	var threads []commentThread
+
+	for _, comment := range comments {
+		thread := commentThread{
+			FileId: file.Id,
+			FileName: file.Name,
+			CommentId: comment.Id,
+			Author: authorName(comment.Author),
+			CreatedTime: comment.CreatedTime,
+			Resolved: comment.Resolved,
+			Content: comment.Content,
+			Replies: []commentReply{},
+		}
+		if comment.QuotedFileContent != nil {
+			thread.QuotedText = comment.QuotedFileContent.Value
+		}
+
+		for _, reply := range comment.Replies {
+			thread.Replies = append(thread.Replies, commentReply{
+				ReplyId: reply.Id,
+				Author: authorName(reply.Author),
+				CreatedTime: reply.CreatedTime,
+				Action: reply.Action,
+				Content: reply.Content,
+			})
+		}
+
+		threads = append(threads, thread)
+	}
+
+	return threads
+}
+
+// Collects the comment threads of every file inside a folder and its subfolders.
+func (client *driveClient) getFolderCommentThreads (folderId string) ([]commentThread, error) {
+	var threads []commentThread
+
+	iterator := client.listFolder(context.Background(), folderId, listOptions{})
+	for iterator.Next() {
+		file := iterator.File()
+
+		if file.MimeType == "application/vnd.google-apps.folder" {
+			folderThreads, err := client.getFolderCommentThreads(file.Id)
+			if err != nil {
+				return nil, err
+			}
+			threads = append(threads, folderThreads...)
+			continue
+		}
+
+		comments, err := client.listComments(file.Id, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		threads = append(threads, newCommentThreads(file, comments)...)
+	}
+
+	return threads, iterator.Err()
+}
+
+// This function exports every comment thread of a folder and its subfolders. You have to provide the folder URL or ID, where to write the export and its format, which can be "json" or "csv".
+//
+// The JSON export is a list of threads, each one with its replies. The CSV export has one line per comment and per reply, and the reply ID is empty on the comment lines.
+func (client *driveClient) exportComments (folderUrl string, writer io.Writer, format string) error {
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("comments cannot be exported to %q, use \"json\" or \"csv\"", format)
+	}
+
+	folderId, err := client.getFolderId(folderUrl)
+	if err != nil {
+		return err
+	}
+
+	threads, err := client.getFolderCommentThreads(folderId)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if threads == nil {
+			threads = []commentThread{}
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(threads)
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write([]string{"fileId", "fileName", "commentId", "replyId", "author", "createdTime", "resolved", "action", "content"})
+	for _, thread := range threads {
+		csvWriter.Write([]string{thread.FileId, thread.FileName, thread.CommentId, "", thread.Author, thread.CreatedTime, strconv.FormatBool(thread.Resolved), "", thread.Content})
+
+		for _, reply := range thread.Replies {
+			csvWriter.Write([]string{thread.FileId, thread.FileName, thread.CommentId, reply.ReplyId, reply.Author, reply.CreatedTime, "", reply.Action, reply.Content})
+		}
+	}
+	csvWriter.Flush()
+
+	return csvWriter.Error()
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// ========== This section is responsible for the configuration of the program ==========
+
+// Types of the configuration values.
+type configKind int
+
+const (
+	stringSetting configKind = iota
+	boolSetting
+	intSetting
+	listSetting
+)
+
+// A setting of the program. Its value comes from the first of these places that has it: the flag, the environment variable, the config file and, at last, the default.
+//
+// Name is the key used in the config file. Choices, when given, are the only values accepted. RequiredIf makes the setting required when another one has a given value, like "token_store=encrypted-file". Secret values are hidden when the configuration is printed.
+type configKey struct {
+	Name        string
+	Variable    string
+	Flag        string
+	Kind        configKind
+	Default     string
+	Choices     []string
+	Required    bool
+	RequiredIf  string
+	Secret      bool
+	Description string
+}
+
+// The environment variable with the path of the config file.
+const configFileVariable = "GOOGLE_CONFIG_FILE"
+
+// The folder, inside the user config folder, where the config file is searched when there is none in the working directory.
+const configDirName = "google-drive-api"
+
+// Every setting of the program.
+var configKeys = []configKey{
+	{Name: "profile", Variable: profileVariable, Flag: "profile", Description: "account profile to use, from " + profilesPath},
+	{Name: "auth_method", Variable: "GOOGLE_AUTH_METHOD", Default: oauthAuth, Choices: []string{oauthAuth, serviceAccountAuth, defaultCredentialsAuth, tokenSourceAuth}, Description: "how the program authenticates"},
+	{Name: "credentials_file", Variable: "GOOGLE_CREDENTIALS_FILE", Description: "OAuth client or service account key, the default depends on the method"},
+	{Name: "token_store", Variable: "GOOGLE_TOKEN_STORE", Default: fileTokenStoreKind, Choices: []string{fileTokenStoreKind, encryptedFileTokenStoreKind, envTokenStoreKind}, Description: "where the OAuth token is kept"},
+	{Name: "token_file", Variable: "GOOGLE_TOKEN_FILE", Default: "credentials/token.json", Description: "file of the OAuth token"},
+	{Name: "token_key", Variable: "GOOGLE_TOKEN_KEY", RequiredIf: "token_store=" + encryptedFileTokenStoreKind, Secret: true, Description: "base64 key of 32 bytes that encrypts the token file"},
+	{Name: "impersonate_subject", Variable: "GOOGLE_IMPERSONATE_SUBJECT", Description: "user impersonated through domain-wide delegation"},
+	{Name: "oauth_headless", Variable: "GOOGLE_OAUTH_HEADLESS", Kind: boolSetting, Default: "false", Description: "print the sign in link instead of opening the browser"},
+	{Name: "scopes", Variable: "GOOGLE_SCOPES", Kind: listSetting, Description: "comma separated OAuth scopes, the default is the full drive scope"},
+	{Name: "root", Variable: "DRIVE_ROOT", Flag: "root", Default: "root", Description: "folder that paths like /Reports/2024 start from"},
+	{Name: "cache", Variable: "DRIVE_CACHE", Flag: "cache", Kind: boolSetting, Default: "false", Description: "read listings and duplicate checks from the local metadata cache"},
+	{Name: "requests_per_second", Variable: "DRIVE_REQUESTS_PER_SECOND", Kind: intSetting, Default: strconv.Itoa(defaultRequestsPerSecond), Description: "limit of requests per second sent to drive, 0 means no limit"},
+	{Name: "push_address", Variable: "DRIVE_PUSH_ADDRESS", Flag: "listen", Default: "127.0.0.1:8080", Description: "local address where the push command receives the notifications of drive"},
+	{Name: "push_url", Variable: "DRIVE_PUSH_URL", Flag: "url", Description: "public HTTPS address that reaches the push address, where drive sends the notifications"},
+}
+
+// The configuration in use. It is loaded by "getSettings" the first time it is needed, unless it was loaded before.
+var settings *layeredConfig
+
+// Returned when the configuration has invalid or missing values. Every problem is listed at once, so they can all be fixed in one go.
+type configError struct {
+	Problems []string
+}
+
+func (err *configError) Error () string {
+	return "the configuration is invalid:\n  - " + strings.Join(err.Problems, "\n  - ")
+}
+
+// A value of the configuration and where it came from, like "flag", "env", "file" or "default".
+type configValue struct {
+	Raw    string
+	Source string
+}
+
+// The values of every setting, already merged from all the places they can come from.
+type layeredConfig struct {
+	File   string
+	keys   []configKey
+	values map[string]configValue
+}
+
+// Returns the path of the config file. An explicit path wins, then the "GOOGLE_CONFIG_FILE" variable, then "config.yaml", "config.yml" or "config.toml" in the working directory and, at last, the same names inside the user config folder. The return is empty when there is no config file.
+func findConfigFile (explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if path := os.Getenv(configFileVariable); path != "" {
+		return path
+	}
+
+	names := []string{"config.yaml", "config.yml", "config.toml"}
+	candidates := append([]string{}, names...)
+	if userDir, err := os.UserConfigDir(); err == nil {
+		for _, name := range names {
+			candidates = append(candidates, filepath.Join(userDir, configDirName, name))
+		}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// Reads the values of a config file as text. Files ending in ".toml" are read as TOML and every other one as YAML. Lists become comma separated values.
+//
+// The known keys are returned even when there are unknown ones, so the configuration can still be printed.
+func readConfigFile (path string, keys []configKey) (map[string]string, error) {
+	values := make(map[string]string)
+	if path == "" {
+		return values, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the config file: %w", err)
+	}
+
+	var content map[string]interface{}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(data, &content)
+	} else {
+		err = yaml.Unmarshal(data, &content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the config file %q: %w", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, key := range keys {
+		known[key.Name] = true
+	}
+
+	var problems []string
+	for name, value := range content {
+		if !known[name] {
+			problems = append(problems, fmt.Sprintf("%s: unknown key %q", path, name))
+			continue
+		}
+
+		if list, ok := value.([]interface{}); ok {
+			var items []string
+			for _, item := range list {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[name] = strings.Join(items, ",")
+		} else if value != nil {
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	if len(problems) > 0 {
+		return values, &configError{Problems: problems}
+	}
+
+	return values, nil
+}
+
+// This function loads the configuration. You have to provide the settings, the parsed flags, which can be nil, and the path of the config file, which can be empty to search for one as in "findConfigFile".
+//
+// Only the flags set in the command line count, their defaults never hide the other places. The ".env" file of the working directory, when there is one, is read as environment variables that are not set yet. Every value is validated, and a "configError" lists all the problems found. The configuration is returned along with it, so it can still be printed.
+func loadConfig (keys []configKey, flags *flag.FlagSet, filePath string) (*layeredConfig, error) {
+	// The ".env" file is optional, the variables may come from the environment itself.
+	godotenv.Load(".env")
+
+	config := &layeredConfig{
+		File: findConfigFile(filePath),
+		keys: keys,
+		values: make(map[string]configValue),
+	}
+
+	var problems []string
+	fileValues, err := readConfigFile(config.File, keys)
+	if configErr, ok := err.(*configError); ok {
+		problems = append(problems, configErr.Problems...)
+	} else if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	setFlags := make(map[string]string)
+	if flags != nil {
+		flags.Visit(func(set *flag.Flag) {
+			setFlags[set.Name] = set.Value.String()
+		})
+	}
+
+	for _, key := range keys {
+		value := configValue{Raw: key.Default, Source: "default"}
+
+		if fileValue, ok := fileValues[key.Name]; ok {
+			value = configValue{Raw: fileValue, Source: "file"}
+		}
+		if envValue := os.Getenv(key.Variable); key.Variable != "" && envValue != "" {
+			value = configValue{Raw: envValue, Source: "env"}
+		}
+		if flagValue, ok := setFlags[key.Flag]; ok && key.Flag != "" {
+			value = configValue{Raw: flagValue, Source: "flag"}
+		}
+
+		config.values[key.Name] = value
+	}
+
+	if configErr, ok := config.validate().(*configError); ok {
+		problems = append(problems, configErr.Problems...)
+	}
+	if len(problems) > 0 {
+		return config, &configError{Problems: problems}
+	}
+
+	return config, nil
+}
+
+// Tells how a setting can be given, used by the error messages.
+func (key configKey) howToSet () string {
+	ways := []string{fmt.Sprintf("%q in the config file", key.Name)}
+	if key.Variable != "" {
+		ways = append(ways, "the "+key.Variable+" variable")
+	}
+	if key.Flag != "" {
+		ways = append(ways, "the -"+key.Flag+" flag")
+	}
+
+	return strings.Join(ways, ", ")
+}
+
+// Checks the type, the choices and the presence of every value.
+func (config *layeredConfig) validate () error {
+	var problems []string
+
+	for _, key := range config.keys {
+		value := config.values[key.Name]
+		where := fmt.Sprintf("%s (from %s)", key.Name, value.Source)
+
+		if value.Raw == "" {
+			required := key.Required
+			if key.RequiredIf != "" {
+				parts := strings.SplitN(key.RequiredIf, "=", 2)
+				required = len(parts) == 2 && config.String(parts[0]) == parts[1]
+			}
+			if required {
+				reason := "it is required"
+				if key.RequiredIf != "" {
+					reason = "it is required when " + key.RequiredIf
+				}
+				problems = append(problems, fmt.Sprintf("%s is missing, %s: set it with %s", key.Name, reason, key.howToSet()))
+			}
+			continue
+		}
+
+		switch key.Kind {
+		case boolSetting:
+			if _, err := strconv.ParseBool(value.Raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a boolean, use true or false", where, value.Raw))
+			}
+		case intSetting:
+			if _, err := strconv.Atoi(value.Raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a whole number", where, value.Raw))
+			}
+		}
+
+		if len(key.Choices) > 0 {
+			valid := false
+			for _, choice := range key.Choices {
+				valid = valid || choice == value.Raw
+			}
+			if !valid {
+				problems = append(problems, fmt.Sprintf("%s: %q is not valid, use one of: %s", where, value.Raw, strings.Join(key.Choices, ", ")))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &configError{Problems: problems}
+	}
+	return nil
+}
+
+// Returns the value of a setting as text.
+func (config *layeredConfig) String (name string) string {
+	return config.values[name].Raw
+}
+
+// Returns the value of a boolean setting. Invalid values were already reported when the configuration was loaded, so they are false here.
+func (config *layeredConfig) Bool (name string) bool {
+	value, _ := strconv.ParseBool(config.values[name].Raw)
+	return value
+}
+
+// Returns the value of a whole number setting, zero when it is empty.
+func (config *layeredConfig) Int (name string) int {
+	value, _ := strconv.Atoi(config.values[name].Raw)
+	return value
+}
+
+// Returns the items of a list setting, without the empty ones.
+func (config *layeredConfig) List (name string) []string {
+	var items []string
+	for _, item := range strings.Split(config.values[name].Raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Returns where the value of a setting came from.
+func (config *layeredConfig) Source (name string) string {
+	return config.values[name].Source
+}
+
+// Prints every setting with its effective value and where it came from. Secret values are hidden.
+func (config *layeredConfig) print (output io.Writer) error {
+	file := config.File
+	if file == "" {
+		file = "none"
+	}
+	fmt.Fprintf(output, "Config file: %s\n\n", file)
+
+	writer := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	for _, key := range config.keys {
+		value := config.values[key.Name]
+		shown := strconv.Quote(value.Raw)
+		if key.Secret && value.Raw != "" {
+			shown = "(hidden)"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", key.Name, shown, value.Source)
+	}
+
+	return writer.Flush()
+}
+
+// Returns the configuration in use, loading it from the environment and the config file when it was not loaded yet.
+func getSettings () (*layeredConfig, error) {
+	if settings == nil {
+		loaded, err := loadConfig(configKeys, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		settings = loaded
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to download whole folders from drive ==========
+
+// How many files are downloaded at the same time by "downloadFolder".
+const downloadWorkers = 4
+
+// Holds a drive file that could not be downloaded, along with the local path it should have been written to.
+type downloadFailure struct {
+	Path string
+	Err  error
+}
+
+// A drive file waiting to be written to a local path.
+type downloadJob struct {
+	File *drive.File
+	Path string
+}
+
+// Replaces the characters that cannot be part of a local file name, so a drive name like "a/b" does not escape its folder. Names that point to a folder by themselves, like "." and "..", and empty names become "_".
+func localFileName (name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+
+	return name
+}
+
+// Joins a drive name to a local folder, checking that the result is still inside that folder.
+func localPathInside (folder string, name string) (string, error) {
+	path := filepath.Join(folder, localFileName(name))
+
+	relative, err := filepath.Rel(folder, path)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("the name %q would be written outside of %q", name, folder)
+	}
+
+	return path, nil
+}
+
+// Returns the path itself when no other item of the same folder uses it yet, or the path with a number before its extension, like "report (1).docx", when one does. The path returned is marked as used.
+//
+// Drive allows many items with the same name in a folder, and different names can also become the same local name, like "a/b" and "a_b". Paths are compared ignoring case, since some file systems do the same.
+func uniqueLocalPath (path string, used map[string]bool) string {
+	extension := filepath.Ext(path)
+	base := strings.TrimSuffix(path, extension)
+
+	candidate := path
+	for i := 1; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, extension)
+	}
+	used[strings.ToLower(candidate)] = true
+
+	return candidate
+}
+
+// This function downloads a whole drive folder to a local folder, rebuilding every subfolder on disk. You have to provide the drive folder URL or ID and the local root folder.
+//
+// Google workspace files are exported to their default format, like "docx" for Docs and "xlsx" for Sheets. Files are downloaded concurrently. When a single file or subfolder fails, the download keeps going and every failure is returned at the end, so an empty slice means that everything was downloaded.
+//
+// Items of the same drive folder that would be written to the same local path get a number in their names, like "report (1).docx", so no file is written twice.
+//
+// Please note that this function *DOES NOT* checks for duplicates in the local folder. So, if there already is a file with the same name, it will be overwritten.
+func (client *driveClient) downloadFolder (folderUrl string, localRoot string) ([]downloadFailure, error) {
+	folderId, err := client.getFolderId(folderUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(localRoot, 0755); err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan downloadJob)
+	var failures []downloadFailure
+	var mutex sync.Mutex
+	addFailure := func(path string, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		failures = append(failures, downloadFailure{Path: path, Err: err})
+	}
+
+	var workers sync.WaitGroup
+	for i := 0; i < downloadWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				if err := client.downloadFileTo(job.File, "", job.Path); err != nil {
+					addFailure(job.Path, err)
+				}
+			}
+		}()
+	}
+
+	client.queueFolderDownload(folderId, localRoot, jobs, addFailure)
+	close(jobs)
+	workers.Wait()
+
+	return failures, nil
+}
+
+// Creates the local folder for a drive folder and sends each one of its files to the download workers, calling itself for every subfolder.
+func (client *driveClient) queueFolderDownload (folderId string, localPath string, jobs chan<- downloadJob, addFailure func(string, error)) {
+	files, err := client.getFolderChildren(folderId, "id, name, mimeType")
+	if err != nil {
+		addFailure(localPath, err)
+		return
+	}
+
+	used := make(map[string]bool)
+	for _, file := range files {
+		filePath, err := localPathInside(localPath, file.Name)
+		if err != nil {
+			addFailure(localPath, err)
+			continue
+		}
+
+		if file.MimeType == "application/vnd.google-apps.folder" {
+			filePath = uniqueLocalPath(filePath, used)
+			if err := os.MkdirAll(filePath, 0755); err != nil {
+				addFailure(filePath, err)
+				continue
+			}
+			client.queueFolderDownload(file.Id, filePath, jobs, addFailure)
+			continue
+		}
+
+		if format := defaultExportFormat(file); format != "" {
+			filePath = fmt.Sprintf("%s.%s", filePath, format)
+		}
+		filePath = uniqueLocalPath(filePath, used)
+
+		jobs <- downloadJob{File: file, Path: filePath}
+	}
+}
+
+// Prints every failure of a folder download, or a success message when there is none.
+func printDownloadFailures (failures []downloadFailure) {
+	if len(failures) == 0 {
+		prettyPrinter("Every file was downloaded!")
+		return
+	}
+
+	prettyPrinter(fmt.Sprintf("%d items could not be downloaded:", len(failures)))
+	for _, failure := range failures {
+		errorPrinter(fmt.Errorf("%s: %w", failure.Path, failure.Err))
+	}
+}
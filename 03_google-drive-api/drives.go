@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible for shared drives ==========
+
+// Returns every shared drive that the user can access.
+func (client *driveClient) listSharedDrives () ([]*drive.Drive, error) {
+	var drives []*drive.Drive
+
+	err := client.Service.Drives.List().PageSize(100).Pages(context.Background(), func(driveList *drive.DriveList) error {
+		drives = append(drives, driveList.Drives...)
+		return nil
+	})
+
+	return drives, err
+}
+
+// Searches for a shared drive by its exact name. You can use the returned drive ID as a folder ID, or as the "DriveId" of the listing options.
+//
+// Please note that shared drive names are not unique, so an error is returned if no drive or more than one drive has the given name.
+func (client *driveClient) getSharedDriveByName (name string) (*drive.Drive, error) {
+	var drives []*drive.Drive
+
+	err := client.Service.Drives.List().Q(string(nameEquals(name))).Pages(context.Background(), func(driveList *drive.DriveList) error {
+		drives = append(drives, driveList.Drives...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(drives) {
+	case 0:
+		return nil, fmt.Errorf("there is no shared drive named %q", name)
+	case 1:
+		return drives[0], nil
+	}
+
+	return nil, fmt.Errorf("there are %d shared drives named %q, use the drive ID instead", len(drives), name)
+}
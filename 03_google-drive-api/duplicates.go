@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to find files with the same content ==========
+
+// Fields of the files compared by the functions below.
+const duplicateFields = "id, name, mimeType, parents, size, md5Checksum, createdTime"
+
+// Files that have the same content, found by their md5 checksum and size. The files are sorted from the oldest to the newest.
+type duplicateGroup struct {
+	Md5Checksum string        `json:"md5Checksum"`
+	Size        int64         `json:"size"`
+	Files       []*drive.File `json:"files"`
+}
+
+// Options of "dedupeFiles". When PreferredFolder is set, the copy inside that folder is kept, otherwise the oldest copy is. When DryRun is true, nothing is trashed and only the files that would be are returned.
+type dedupeOptions struct {
+	PreferredFolder string
+	DryRun          bool
+}
+
+// This function finds every file inside a folder and its subfolders that has the same content as another one, no matter their names. You have to provide the folder URL or ID. When the metadata cache is enabled, the folders are read from the cache.
+//
+// Please note that google workspace files, like Docs and Sheets, have no checksum and are never reported. Unlike "checkFileDuplicates", which compares names and types, renamed copies are found and different files with the same name are not.
+func (client *driveClient) findContentDuplicates (folderUrl string) ([]duplicateGroup, error) {
+	folderId, err := client.getFolderId(folderUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	byContent := make(map[string]*duplicateGroup)
+	var order []string
+	seen := make(map[string]bool)
+
+	var walk func(folderId string) error
+	walk = func(folderId string) error {
+		files, err := client.getFolderChildren(folderId, duplicateFields)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if seen[file.Id] {
+				continue
+			}
+			seen[file.Id] = true
+
+			if file.MimeType == "application/vnd.google-apps.folder" {
+				if err := walk(file.Id); err != nil {
+					return err
+				}
+				continue
+			}
+			if file.Md5Checksum == "" {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%d", file.Md5Checksum, file.Size)
+			group, ok := byContent[key]
+			if !ok {
+				group = &duplicateGroup{Md5Checksum: file.Md5Checksum, Size: file.Size}
+				byContent[key] = group
+				order = append(order, key)
+			}
+			group.Files = append(group.Files, file)
+		}
+
+		return nil
+	}
+
+	if err := walk(folderId); err != nil {
+		return nil, err
+	}
+
+	var groups []duplicateGroup
+	for _, key := range order {
+		group := byContent[key]
+		if len(group.Files) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group.Files, func(i, j int) bool {
+			return group.Files[i].CreatedTime < group.Files[j].CreatedTime
+		})
+		groups = append(groups, *group)
+	}
+
+	return groups, nil
+}
+
+// Prints every group of duplicates, with the files of each one, followed by how much space the extra copies take.
+func printDuplicateReport (groups []duplicateGroup) {
+	var wasted int64
+	for _, group := range groups {
+		fmt.Printf("%s (%d bytes, %d copies)\n", group.Md5Checksum, group.Size, len(group.Files))
+		for _, file := range group.Files {
+			fmt.Printf("\t%s\t%s\t%s\n", file.Name, file.Id, file.CreatedTime)
+		}
+		wasted += group.Size * int64(len(group.Files)-1)
+	}
+
+	prettyPrinter(fmt.Sprintf("%d groups of duplicates found, the extra copies take %d bytes.", len(groups), wasted))
+}
+
+// Returns the file of a group that should be kept: the first one inside the preferred folder or, when there is none, the oldest one.
+func duplicateToKeep (group duplicateGroup, preferredFolderId string) *drive.File {
+	if preferredFolderId != "" {
+		for _, file := range group.Files {
+			for _, parent := range file.Parents {
+				if parent == preferredFolderId {
+					return file
+				}
+			}
+		}
+	}
+
+	return group.Files[0]
+}
+
+// This function keeps a single file of each group of duplicates and moves the others to the trash, returning the trashed files. They can be brought back with "restoreFile".
+func (client *driveClient) dedupeFiles (groups []duplicateGroup, options dedupeOptions) ([]*drive.File, error) {
+	preferredFolderId := ""
+	if options.PreferredFolder != "" {
+		folderId, err := client.getFolderId(options.PreferredFolder)
+		if err != nil {
+			return nil, err
+		}
+		preferredFolderId = folderId
+	}
+
+	var trashed []*drive.File
+	for _, group := range groups {
+		kept := duplicateToKeep(group, preferredFolderId)
+
+		for _, file := range group.Files {
+			if file.Id == kept.Id {
+				continue
+			}
+
+			if !options.DryRun {
+				if _, err := client.trashFile(file.Id); err != nil {
+					return trashed, fmt.Errorf("%s: %w", file.Name, err)
+				}
+			}
+			trashed = append(trashed, file)
+		}
+	}
+
+	return trashed, nil
+}
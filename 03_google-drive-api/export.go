@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to export google workspace files ==========
+
+// Friendly file formats and the MIME types that drive expects when exporting to them.
+var exportMimeTypes = map[string]string{
+	"pdf":  "application/pdf",
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"csv":  "text/csv",
+	"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"odt":  "application/vnd.oasis.opendocument.text",
+	"txt":  "text/plain",
+	"html": "text/html",
+	"png":  "image/png",
+}
+
+// The formats that each google workspace file type can be exported to. The first format of each list is the default one.
+var workspaceExportFormats = map[string][]string{
+	"application/vnd.google-apps.document":     {"docx", "pdf", "odt", "txt", "html"},
+	"application/vnd.google-apps.spreadsheet":  {"xlsx", "pdf", "csv"},
+	"application/vnd.google-apps.presentation": {"pptx", "pdf", "txt"},
+	"application/vnd.google-apps.drawing":      {"png", "pdf"},
+}
+
+// Returned when a google workspace file cannot be exported to the requested format, either because the format is unknown or because drive does not support it for that file type.
+type exportFormatError struct {
+	MimeType string
+	Format   string
+}
+
+func (err *exportFormatError) Error() string {
+	formats, ok := workspaceExportFormats[err.MimeType]
+	if !ok {
+		return fmt.Sprintf("files of type %q cannot be exported", err.MimeType)
+	}
+
+	sorted := append([]string{}, formats...)
+	sort.Strings(sorted)
+	return fmt.Sprintf("files of type %q cannot be exported to %q, the supported formats are: %s", err.MimeType, err.Format, strings.Join(sorted, ", "))
+}
+
+// Checks if a drive file is a native google workspace file (Docs, Sheets, Slides, Drawings, folders...), which has no binary content to download.
+func isWorkspaceFile (file *drive.File) bool {
+	return strings.HasPrefix(file.MimeType, "application/vnd.google-apps.")
+}
+
+// Returns the MIME type drive expects to export a google workspace file to a friendly format, like "pdf" or "xlsx".
+//
+// When the format is empty, the default format for that file type is used. An "exportFormatError" is returned for every unsupported combination.
+func getExportMimeType (mimeType string, fileFormat string) (string, error) {
+	formats, ok := workspaceExportFormats[mimeType]
+	if !ok {
+		return "", &exportFormatError{MimeType: mimeType, Format: fileFormat}
+	}
+
+	fileFormat = strings.ToLower(strings.TrimPrefix(fileFormat, "."))
+	if fileFormat == "" {
+		fileFormat = formats[0]
+	}
+
+	for _, format := range formats {
+		if format == fileFormat {
+			return exportMimeTypes[format], nil
+		}
+	}
+
+	return "", &exportFormatError{MimeType: mimeType, Format: fileFormat}
+}
+
+// Returns the extension a drive file gets when downloaded without choosing a format. Only google workspace files have one, every other file keeps its own name.
+func defaultExportFormat (file *drive.File) string {
+	if formats, ok := workspaceExportFormats[file.MimeType]; ok {
+		return formats[0]
+	}
+
+	return ""
+}
+
+// Returns the content of a drive file. Google workspace files are exported to the given format, every other file is downloaded as it is and the format is ignored.
+//
+// Do not forget to close the content afterwards.
+func (client *driveClient) getFileContent (file *drive.File, fileFormat string) (io.ReadCloser, error) {
+	if !isWorkspaceFile(file) {
+		data, err := client.Service.Files.Get(file.Id).SupportsAllDrives(true).Download()
+		if err != nil {
+			return nil, err
+		}
+		return data.Body, nil
+	}
+
+	exportMimeType, err := getExportMimeType(file.MimeType, fileFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.Service.Files.Export(file.Id, exportMimeType).Download()
+	if err != nil {
+		return nil, err
+	}
+	return data.Body, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ========== This section is responsible to read IDs from drive links ==========
+
+// Returned when a reference is neither a valid drive link, nor a valid ID.
+type invalidReferenceError struct {
+	Reference string
+	Reason    string
+}
+
+func (err *invalidReferenceError) Error () string {
+	return fmt.Sprintf("%q is not a valid drive link or ID: %s", err.Reference, err.Reason)
+}
+
+// Hosts whose links carry drive IDs.
+var driveLinkHosts = map[string]bool{
+	"drive.google.com": true,
+	"docs.google.com": true,
+}
+
+// Checks if an ID only has the characters drive uses: letters, digits, "-" and "_".
+func isValidDriveId (id string) bool {
+	if id == "" {
+		return false
+	}
+
+	for _, char := range id {
+		isLetter := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+		isDigit := char >= '0' && char <= '9'
+		if !isLetter && !isDigit && char != '-' && char != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// This function reads the ID of a drive item from a link. Every link shape shared by drive and the google workspace editors is understood, like:
+// 
+//	https://drive.google.com/drive/folders/<id>
+//	https://drive.google.com/drive/u/1/folders/<id>?usp=sharing
+//	https://drive.google.com/drive/shared-drives/<id>
+//	https://drive.google.com/drive/u/0/shared-drives/<id>
+//	https://drive.google.com/file/d/<id>/view
+//	https://drive.google.com/open?id=<id>
+//	https://docs.google.com/spreadsheets/d/<id>/edit#gid=0
+// 
+// The scheme can be left out, like "drive.google.com/file/d/<id>". Query strings and fragments that do not carry the ID are ignored.
+func parseDriveLink (link string) (string, error) {
+	raw := link
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", &invalidReferenceError{Reference: link, Reason: err.Error()}
+	}
+	if !driveLinkHosts[strings.ToLower(parsed.Hostname())] {
+		return "", &invalidReferenceError{Reference: link, Reason: fmt.Sprintf("%q is not a drive host", parsed.Hostname())}
+	}
+
+	id := parsed.Query().Get("id")
+	if id == "" {
+		segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		for index := 0; index < len(segments)-1; index++ {
+			if segments[index] == "folders" || segments[index] == "shared-drives" || segments[index] == "d" {
+				id = segments[index+1]
+				break
+			}
+		}
+	}
+
+	if id == "" {
+		return "", &invalidReferenceError{Reference: link, Reason: "the link does not point to a file, folder or shared drive"}
+	}
+	// Published forms use "/forms/d/e/<id>", whose ID is not a drive ID.
+	if id == "e" {
+		return "", &invalidReferenceError{Reference: link, Reason: "published links do not carry the drive ID, use the editor link"}
+	}
+	if !isValidDriveId(id) {
+		return "", &invalidReferenceError{Reference: link, Reason: fmt.Sprintf("%q has characters that drive IDs do not use", id)}
+	}
+
+	return id, nil
+}
+
+// Checks if a reference looks like a link instead of an ID.
+func isDriveLink (reference string) bool {
+	return strings.Contains(reference, "://") || strings.Contains(reference, "/")
+}
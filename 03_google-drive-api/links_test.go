@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDriveLink (t *testing.T) {
+	tests := []struct {
+		link string
+		id   string
+	}{
+		{"https://drive.google.com/drive/folders/abc_123-XYZ", "abc_123-XYZ"},
+		{"https://drive.google.com/drive/u/1/folders/abc123?usp=sharing", "abc123"},
+		{"https://drive.google.com/drive/shared-drives/0AbCdEf", "0AbCdEf"},
+		{"https://drive.google.com/drive/u/0/shared-drives/0AbCdEf", "0AbCdEf"},
+		{"https://drive.google.com/file/d/abc123/view", "abc123"},
+		{"https://drive.google.com/open?id=abc123", "abc123"},
+		{"https://docs.google.com/spreadsheets/d/abc123/edit#gid=0", "abc123"},
+		{"drive.google.com/file/d/abc123", "abc123"},
+	}
+
+	for _, test := range tests {
+		id, err := parseDriveLink(test.link)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.link, err)
+			continue
+		}
+		if id != test.id {
+			t.Errorf("%s: expected ID %q, got %q", test.link, test.id, id)
+		}
+	}
+}
+
+func TestParseDriveLinkRejectsInvalidLinks (t *testing.T) {
+	links := []string{
+		"https://example.com/drive/folders/abc123",
+		"https://drive.google.com/drive/folders/abc$123",
+		"https://drive.google.com/drive/my-drive",
+		"https://docs.google.com/forms/d/e/abc123/viewform",
+	}
+
+	for _, link := range links {
+		_, err := parseDriveLink(link)
+		var invalid *invalidReferenceError
+		if !errors.As(err, &invalid) {
+			t.Errorf("%s: expected an invalid reference error, got %v", link, err)
+		}
+	}
+}
@@ -2,98 +2,43 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
-	"github.com/joho/godotenv"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
-	"google.golang.org/api/option"
+	"google.golang.org/api/googleapi"
 )
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tokFile := "credentials/token.json"
-	tok, err := tokenFromFile(tokFile)
-	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
-	}
-	return config.Client(context.Background(), tok)
-}
-
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-			"authorization code: \n%v\n", authURL)
-
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
-	}
-
-	tok, err := config.Exchange(context.TODO(), authCode)
-	if err != nil {
-		log.Fatalf("Unable to retrieve token from web %v", err)
-	}
-	return tok
-}
-
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
-}
-
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+// Returns the client that sends every request with the tokens of the given source.
+// 
+// Every request sent by the client waits for the global rate limiter, and temporary errors are retried following "apiRetryPolicy".
+func getClient(tokenSource oauth2.TokenSource) *http.Client {
+	client := oauth2.NewClient(context.Background(), tokenSource)
+	client.Transport = &retryTransport{
+		base: &rateLimitedTransport{
+			base: client.Transport,
+			limiter: requestLimiter,
+		},
+		policy: apiRetryPolicy,
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	return client
 }
 
-// Gets the service used to make every drive operation
-func getService () *drive.Service {
-	ctx := context.Background()
-	b, err := ioutil.ReadFile("credentials/creds.json")
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
-	}
-
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, drive.DriveScope)
-	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
-	}
-	client := getClient(config)
 
-	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+// Gets the default client, the one used by the command-line interface. The account is chosen by "activeProfile", see "newDriveClient".
+func getDefaultClient () *driveClient {
+	client, err := newDriveClient(activeProfile)
 	if err != nil {
 		log.Fatalf("Unable to retrieve Drive client: %v", err)
 	}
 
-	return srv
+	return client
 }
 
 // ====================================== Miscelaneous ======================================
@@ -102,7 +47,7 @@ func getService () *drive.Service {
 // If it is not, this function prints it.
 func errorPrinter (err error) {
 	if err != nil {
-		fmt.Printf(`
+		fmt.Fprintf(messageOutput, `
 		------------------------
 		The following error occurred:
 		%s
@@ -114,100 +59,236 @@ func errorPrinter (err error) {
 // Prints a message in a more distinguible way.
 func prettyPrinter (msgs... string) {
 	for _, msg := range(msgs) {
-		fmt.Printf(`
+		fmt.Fprintf(messageOutput, `
 	-------------------
 	%s
 	-------------------`, msg)
 	}
 }
 
-// Retrieves environment variables from the ".env" file stored in this repo.
-func getGoDotEnvVariable(key string) string {
-
-	// load .env file
-	err := godotenv.Load(".env")
-  
-	if err != nil {
-	  log.Fatalf("Error loading .env file")
-	}
-  
-	return os.Getenv(key)
-}
-
 // Checks for file duplicates inside a folder. If it finds one, the return will be true.
 // 
 // Please note that the file search is based in name and type, so independently of dates and other metadata, if two files have the same name and type, it will return true.
-func checkFileDuplicates (currentFile *drive.File, folderUrl string) (bool, error) {
-	files, err := getFolderInfos(folderUrl)
+func (client *driveClient) checkFileDuplicates (currentFile *drive.File, folderUrl string) (bool, error) {
+	file, err := client.getDuplicate(currentFile, folderUrl)
 
-	for _, file := range files {
-		if file.Name == currentFile.Name && 
-		   file.MimeType == currentFile.MimeType {
-			return true, err
-		}
-	}
-
-	return false, err
+	return file != nil, err
 }
 
 // Searches inside a folder for a file duplicate, when it finds, return the file found.
 // If no file is found, the return is a nil pointer.
-func getDuplicate (currentFile *drive.File, parentUrl string) (*drive.File, error) {
-	files, err := getFolderInfos(parentUrl)
+func (client *driveClient) getDuplicate (currentFile *drive.File, parentUrl string) (*drive.File, error) {
+	if client.Cache != nil {
+		files, err := client.getFolderChildren(parentUrl, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.Name == currentFile.Name && (currentFile.MimeType == "" || file.MimeType == currentFile.MimeType) {
+				return file, nil
+			}
+		}
+		return nil, nil
+	}
 
-	var file *drive.File = nil
+	filter := nameEquals(currentFile.Name)
+	if currentFile.MimeType != "" {
+		filter = allOf(filter, mimeTypeEquals(currentFile.MimeType))
+	}
 
-	for _, file = range files {
-		if file.Name == currentFile.Name && 
-		   file.MimeType == currentFile.MimeType {
-			break
-		}
+	iterator := client.listFolder(context.Background(), parentUrl, listOptions{PageSize: 1, Filter: filter})
+	if iterator.Next() {
+		return iterator.File(), nil
 	}
 	
-	return file, err
+	return nil, iterator.Err()
 }
 
 // ========== This section is responsible to fetch files data from a drive folder ==========
 
-// Retrieves the ID from a drive URL. Firstly, it checks for the "https" prefix, if it does not have one, the function just returns the url given, assuming that it is already an ID.
-func getFolderId (url string) string {
-	if strings.HasPrefix(url, "https"){
-		arr := strings.Split(url, "folders/")
+// Retrieves the ID from a drive URL, path or ID. Links are read by "parseDriveLink", so any link shared by drive works, like the ones of folders, files, docs and sheets. Anything else is taken as an ID, and an "invalidReferenceError" is returned when it has characters that drive IDs do not use.
+// 
+// Paths that start with "/", like "/Reports/2024/Q1", are resolved with "resolvePath".
+func (client *driveClient) getFolderId (url string) (string, error) {
+	url = strings.TrimSpace(url)
+
+	if isDrivePath(url) {
+		return client.resolvePath(url, false)
+	}
+
+	if isDriveLink(url) {
+		return parseDriveLink(url)
+	}
 
-		return arr[1]
+	if url == "" {
+		return "", &invalidReferenceError{Reference: url, Reason: "it is empty"}
+	}
+	if !isValidDriveId(url) {
+		return "", &invalidReferenceError{Reference: url, Reason: "it has characters that drive IDs do not use"}
 	}
 
-	return url
+	return url, nil
 }
 
-// Returns all the files inside a folder. You must provide an drive folder url or ID.
-func getFolderFiles (url string) (*drive.FileList, error){
-	folderId := getFolderId(url)
-	query := fmt.Sprintf("parents = '%s'", folderId)
+// Returns one page of the files inside a folder. You must provide an drive folder url or ID, along with the token of the page you want. An empty token returns the first page.
+// 
+// To go through every file of a folder, prefer "listFolder", which follows the pages by itself.
+func (client *driveClient) getFolderFiles (url string, pageToken string) (*drive.FileList, error){
+	folderId, err := client.getFolderId(url)
+	if err != nil {
+		return nil, err
+	}
+	query := allOf(hasParent(folderId), isTrashed(false))
 
-	fileList, err := srv.Files.List().Q(query).Do()
+	fileList, err := client.Service.Files.List().
+		Q(string(query)).
+		Corpora("allDrives").
+		IncludeItemsFromAllDrives(true).
+		SupportsAllDrives(true).
+		PageToken(pageToken).
+		Do()
 
 	return fileList, err
 }
 
-// Retrives an array of files from the "drive.FileList" structure. It checks for any additional pages and retrieves the files from those as well.
-func getFolderInfos (folderUrl string) ([]*drive.File, error) {
-	fileList, err := getFolderFiles(folderUrl)
-	files := fileList.Files
+// Options of a folder listing. PageSize is how many files are fetched per request, zero lets drive choose. Fields are the file fields that drive returns, like "id, name, size", and when empty only the ID, name and type are returned. Filter narrows down the listing, like "nameContains("report")".
+// 
+// DriveId limits the search to a single shared drive, which is faster than searching every drive the user can access.
+type listOptions struct {
+	PageSize int64
+	Fields   string
+	Filter   driveQuery
+	DriveId  string
+}
+
+// Goes through the files of a folder one at a time, fetching a new page only when the previous one is over. This way, a folder with millions of files never has to be held in memory.
+// 
+// Use it like a "bufio.Scanner":
+// 
+//	files := listFolder(ctx, folderUrl, listOptions{})
+//	for files.Next() {
+//		file := files.File()
+//	}
+//	if err := files.Err(); err != nil {
+//	}
+type folderIterator struct {
+	ctx       context.Context
+	call      *drive.FilesListCall
+	page      []*drive.File
+	file      *drive.File
+	pageToken string
+	lastPage  bool
+	err       error
+}
+
+// Returns an iterator over the files inside a folder that are not in the trash. You must provide an drive folder url or ID.
+// 
+// The listing stops with the context error when the context is cancelled.
+func (client *driveClient) listFolder (ctx context.Context, folderUrl string, options listOptions) *folderIterator {
+	folderId, err := client.getFolderId(folderUrl)
+	if err != nil {
+		return &folderIterator{ctx: ctx, err: err}
+	}
+	query := allOf(hasParent(folderId), isTrashed(false))
+
+	return client.searchFiles(ctx, query, options)
+}
+
+// Returns an iterator over every file that matches a query, no matter which folder it is in. The filter of the options is combined with the query.
+func (client *driveClient) searchFiles (ctx context.Context, query driveQuery, options listOptions) *folderIterator {
+	query = allOf(query, options.Filter)
+
+	fields := options.Fields
+	if fields == "" {
+		fields = "id, name, mimeType"
+	}
+
+	call := client.Service.Files.List().
+		Q(string(query)).
+		Fields(googleapi.Field(fmt.Sprintf("nextPageToken, files(%s)", fields))).
+		IncludeItemsFromAllDrives(true).
+		SupportsAllDrives(true)
+	if options.DriveId != "" {
+		call = call.Corpora("drive").DriveId(options.DriveId)
+	} else {
+		call = call.Corpora("allDrives")
+	}
+	if options.PageSize > 0 {
+		call = call.PageSize(options.PageSize)
+	}
+
+	return &folderIterator{ctx: ctx, call: call}
+}
+
+// Advances to the next file, fetching the next page when needed. It returns false when there are no more files or when an error occurred, which can be checked with "Err".
+func (iterator *folderIterator) Next () bool {
+	for len(iterator.page) == 0 {
+		if iterator.err != nil || iterator.lastPage {
+			return false
+		}
+		if err := iterator.ctx.Err(); err != nil {
+			iterator.err = err
+			return false
+		}
+
+		fileList, err := iterator.call.PageToken(iterator.pageToken).Context(iterator.ctx).Do()
+		if err != nil {
+			iterator.err = err
+			return false
+		}
+
+		iterator.page = fileList.Files
+		iterator.pageToken = fileList.NextPageToken
+		iterator.lastPage = fileList.NextPageToken == ""
+	}
+
+	iterator.file = iterator.page[0]
+	iterator.page = iterator.page[1:]
+	return true
+}
+
+// Returns the current file, the one reached by the last call to "Next".
+func (iterator *folderIterator) File () *drive.File {
+	return iterator.file
+}
 
-	nextPageToken := fileList.NextPageToken
+// Returns the error that stopped the listing, if there is one.
+func (iterator *folderIterator) Err () error {
+	return iterator.err
+}
 
-	for nextPageToken != "" {
-		if len(files) == 0 {
-			fmt.Println("No files found.")
-			break
+// Returns every file inside a folder that is not in the trash, following all the pages. You must provide the folder ID and the fields that each file should have.
+// 
+// When the metadata cache is enabled, the files come from the cache and have the fields it stores instead.
+func (client *driveClient) getFolderChildren (folderId string, fields string) ([]*drive.File, error) {
+	if client.Cache != nil {
+		id, err := client.getFolderId(folderId)
+		if err != nil {
+			return nil, err
 		}
-		fileList, err = getFolderFiles(folderUrl)
+		return client.Cache.children(id)
+	}
 
-		files = append(files, fileList.Files...)
+	var files []*drive.File
+
+	iterator := client.listFolder(context.Background(), folderId, listOptions{Fields: fields})
+	for iterator.Next() {
+		files = append(files, iterator.File())
 	}
 
-	return files, err
+	return files, iterator.Err()
+}
+
+// Retrives an array with every file inside a folder that is not in the trash, going through all the pages.
+// 
+// Please note that the whole folder is held in memory. For big folders, prefer "listFolder".
+func (client *driveClient) getFolderInfos (folderUrl string) ([]*drive.File, error) {
+	folderId, err := client.getFolderId(folderUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.getFolderChildren(folderId, "")
 }
 
 // ========== This section is responsible to create new folders ==========
@@ -219,9 +300,14 @@ func getFolderInfos (folderUrl string) ([]*drive.File, error) {
 // There is also a "force" parameter that accepts boolean values, only the first value is read. When the first parameter is false or not provided, the creation of the folder *WILL CHECK FOR DUPLICATES*. When the first value is true, the folder creation will be forced, 
 // 
 // This function also returns the folder created, so, if there is a duplicate, it will return the folder that already exists.
-func createFolder (name string, parentUrl string, force... bool) (*drive.File, error){
+func (client *driveClient) createFolder (name string, parentUrl string, force... bool) (*drive.File, error){
+	parentId, err := client.getFolderId(parentUrl)
+	if err != nil {
+		return nil, err
+	}
+
 	var parents []string
-	parents = append(parents, getFolderId(parentUrl))
+	parents = append(parents, parentId)
 
 	newFolder := &drive.File{
 		Name: name,
@@ -230,17 +316,21 @@ func createFolder (name string, parentUrl string, force... bool) (*drive.File, e
 	}
 
 	if len(force) == 0 || !force[0] {
-		isDuplicate, err := checkFileDuplicates(newFolder, parentUrl)
+		isDuplicate, err := client.checkFileDuplicates(newFolder, parentUrl)
 		if err != nil {
 			return nil, err
 		}
 		if isDuplicate {
 			prettyPrinter("This folder already exists!")
-			return getDuplicate(newFolder, parentUrl)
+			return client.getDuplicate(newFolder, parentUrl)
 		}
 	}
 
-	folder, err := srv.Files.Create(newFolder).Fields("id").Do()
+	folder, err := client.Service.Files.Create(newFolder).Fields("id").SupportsAllDrives(true).Do()
+	if err == nil {
+		newFolder.Id = folder.Id
+		client.Cache.remember(newFolder)
+	}
 	return folder, err
 }
 
@@ -253,26 +343,36 @@ func createFolder (name string, parentUrl string, force... bool) (*drive.File, e
 // There is also a "force" parameter that accepts boolean values, only the first value is read. When the first parameter is false or not provided, the function *WILL CHECK FOR DUPLICATES*. When the first value is true, the file copy will be forced, 
 // 
 // This function also returns the file copied, so, if there is a duplicate, it will return the file that already exists.
-func copyFileTo (file *drive.File, destinationFolderId string, force... bool) (*drive.File, error) {
+func (client *driveClient) copyFileTo (file *drive.File, destinationFolderId string, force... bool) (*drive.File, error) {
 
 	if len(force) == 0 || !force[0] {
-		isDuplicate, err := checkFileDuplicates(file, destinationFolderId)
+		isDuplicate, err := client.checkFileDuplicates(file, destinationFolderId)
 		if err != nil {
 			return nil, err
 		}
 		if isDuplicate {
 			prettyPrinter("This file already exists inside the parent folder.")
-			return getDuplicate(file, destinationFolderId)
+			return client.getDuplicate(file, destinationFolderId)
 		}
 	}
 
 	var parents []string
 	parents = append(parents, destinationFolderId)
 
-	fileCopied, err := srv.Files.Copy(file.Id, &drive.File{
+	fileCopied, err := client.Service.Files.Copy(file.Id, &drive.File{
 		Name: file.Name,
 		Parents: parents,
-	}).Do()
+	}).SupportsAllDrives(true).Do()
+	if err == nil {
+		client.Cache.remember(&drive.File{
+			Id: fileCopied.Id,
+			Name: file.Name,
+			MimeType: file.MimeType,
+			Parents: parents,
+			Size: file.Size,
+			Md5Checksum: file.Md5Checksum,
+		})
+	}
 
 	errorPrinter(err)
 	return fileCopied, err
@@ -285,36 +385,63 @@ func copyFileTo (file *drive.File, destinationFolderId string, force... bool) (*
 // There is also a "force" parameter that accepts boolean values, only the first value is read. When the first parameter is false or not provided, the creation of the file *WILL CHECK FOR DUPLICATES*. When the first value is true, the file creation will be forced, 
 // 
 // This function also returns the file created, so, if there is a duplicate, it will return the file that already exists.
-func createFileInsideOf (file *drive.File, force... bool) (*drive.File, error) {
+func (client *driveClient) createFileInsideOf (file *drive.File, force... bool) (*drive.File, error) {
 	for _, parentId := range(file.Parents){
 		if len(force) == 0 || !force[0] {
-			isDuplicate, err := checkFileDuplicates(file, parentId)
+			isDuplicate, err := client.checkFileDuplicates(file, parentId)
 			if err != nil {
 				return nil, err
 			}
 			if isDuplicate {
 				prettyPrinter(fmt.Sprintf("There is already a file with this name and type inside the destination folder.\nThe folder ID is: %s", parentId))
-				return getDuplicate(file, parentId)
+				return client.getDuplicate(file, parentId)
 			}
 		}
 	}
 
-	fileCreated, err := srv.Files.Create(file).Fields("id").Do()
+	fileCreated, err := client.Service.Files.Create(file).Fields("id").SupportsAllDrives(true).Do()
+	if err == nil {
+		client.Cache.remember(&drive.File{Id: fileCreated.Id, Name: file.Name, MimeType: file.MimeType, Parents: file.Parents})
+	}
 
 	return fileCreated, err
 }
 
 // This function moves a file to a given parent. You have to provide the source of the folder, it's destination folder, as well as the file you want to move.
 // 
+// When the source is empty, the file leaves every folder it is in, as listed in its "Parents". A file without parents is only added to the destination.
+// 
 // Please note that this function *DOES NOT* checks for duplicates. So, if there already is a file inside the parent with the same name, it will move the file anyways.
-func moveFileTo (source string, target string, file *drive.File) (*drive.File, error) {
-	sourceId := getFolderId(source)
-	targetId := getFolderId(target)
+func (client *driveClient) moveFileTo (source string, target string, file *drive.File) (*drive.File, error) {
+	removedParents := file.Parents
+	if source != "" {
+		sourceId, err := client.getFolderId(source)
+		if err != nil {
+			return nil, err
+		}
+		removedParents = []string{sourceId}
+	}
+	targetId, err := client.getFolderId(target)
+	if err != nil {
+		return nil, err
+	}
 
-	movedFile, err := srv.Files.Update(
-		file.Id,
-		&drive.File{},
-	).AddParents(targetId).RemoveParents(sourceId).Do()
+	update := client.Service.Files.Update(file.Id, &drive.File{}).AddParents(targetId)
+	if len(removedParents) > 0 {
+		update = update.RemoveParents(strings.Join(removedParents, ","))
+	}
+
+	movedFile, err := update.SupportsAllDrives(true).Do()
+	if err == nil {
+		client.Cache.remember(&drive.File{
+			Id: file.Id,
+			Name: file.Name,
+			MimeType: file.MimeType,
+			Parents: []string{targetId},
+			Size: file.Size,
+			Md5Checksum: file.Md5Checksum,
+		})
+	}
 
 	return movedFile, err
 }
@@ -324,111 +451,98 @@ func moveFileTo (source string, target string, file *drive.File) (*drive.File, e
 // To get the local file, you can use: file, err := os.Open(filePath). Do not forget to close the file afterwards.
 // 
 // Please note that this function *DOES NOT* checks for duplicates. So, if there already is a file inside the parent with the same name, it will upload the new file anyways.
-func uploadFiles (file *os.File, targetDriveFolder string) (*drive.File, error) {
-	targetDriveFolder = getFolderId(targetDriveFolder)
+func (client *driveClient) uploadFiles (file *os.File, targetDriveFolder string) (*drive.File, error) {
+	targetDriveFolder, err := client.getFolderId(targetDriveFolder)
+	if err != nil {
+		return nil, err
+	}
 
 	fileInfo, err := file.Stat()
 	if err != nil {
 		return nil, err
 	}
 
-	uploadedfile, err := srv.Files.Create(&drive.File{
+	uploadedfile, err := client.Service.Files.Create(&drive.File{
 		Name: fileInfo.Name(),
 		Parents: []string{targetDriveFolder},
-	}).Media(file).Do()
+	}).SupportsAllDrives(true).Media(file).Do()
+	if err == nil {
+		client.Cache.remember(&drive.File{Id: uploadedfile.Id, Name: uploadedfile.Name, MimeType: uploadedfile.MimeType, Parents: []string{targetDriveFolder}})
+	}
 
 	return uploadedfile, err
 }
 
 // This function downloads a drive file to a given local parent. You have to provide the drive file, the downloaded file format and the destination local folder that you want to upload the file.
 // 
+// Google Docs, Sheets, Slides and Drawings are exported to the given format, like "pdf", "docx" or "xlsx". If drive cannot export the file to that format, an "exportFormatError" is returned. Every other file is downloaded as it is, and the format is only used as the file extension.
+// 
 // Please note that this function *DOES NOT* checks for duplicates in the local folder. So, if there already is a file inside the folder with the same name, it will download the new file anyways.
-func downloadFiles (file *drive.File, localPath string, fileFormat string) error{
-	data, err := srv.Files.Get(file.Id).Download()
-	if data != nil{
-		os.Chdir(localPath)
-		dowloadedFile, err := os.Create(fmt.Sprintf("%s.%s", file.Name, fileFormat))
+func (client *driveClient) downloadFiles (file *drive.File, localPath string, fileFormat string) error{
+	if file.MimeType == "" || file.Name == "" {
+		var err error
+		file, err = client.Service.Files.Get(file.Id).Fields("id, name, mimeType").SupportsAllDrives(true).Do()
 		if err != nil {
 			return err
 		}
-		io.Copy(dowloadedFile, data.Body)
 	}
 
+	fileName := file.Name
+	if fileFormat != "" {
+		fileName = fmt.Sprintf("%s.%s", file.Name, fileFormat)
+	}
+
+	return client.downloadFileTo(file, fileFormat, filepath.Join(localPath, fileName))
+}
+
+// Downloads the content of a drive file to the exact local path given, creating or truncating the local file. Google workspace files are exported to the given format.
+func (client *driveClient) downloadFileTo (file *drive.File, fileFormat string, localFilePath string) error {
+	data, err := client.getFileContent(file, fileFormat)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	dowloadedFile, err := os.Create(localFilePath)
+	if err != nil {
+		return err
+	}
+	defer dowloadedFile.Close()
+
+	_, err = io.Copy(dowloadedFile, data)
 	return err
 }
 
 // This functions permanently deletes a file. You must provide the file ID to do so.
 // 
 // Please note that this function *DOES NOT* moves the file to the trash, it just deletes it and you cannot retrieve it anymore.
-func permanentlyDeleteFile (fileId string) error {
-	err := srv.Files.Delete(fileId).Do()
+func (client *driveClient) permanentlyDeleteFile (fileId string) error {
+	err := client.Service.Files.Delete(fileId).SupportsAllDrives(true).Do()
+	if err == nil {
+		client.Cache.forget(fileId)
+	}
 	return err
 }
 
-// This function permanently deletes all the files inside the trash.
-func emptyTrash () (error) {
-	err := srv.Files.EmptyTrash().Do()
+// This function deletes a file. You must provide the file ID to do so.
+// 
+// By default, the file is moved to the trash and can be restored with "restoreFile". There is also a "permanently" parameter that accepts boolean values, only the first value is read. When the first value is true, the file is *PERMANENTLY DELETED* with "permanentlyDeleteFile" and you cannot retrieve it anymore.
+func (client *driveClient) deleteFile (fileId string, permanently... bool) error {
+	if len(permanently) > 0 && permanently[0] {
+		return client.permanentlyDeleteFile(fileId)
+	}
+
+	_, err := client.trashFile(fileId)
 	return err
 }
 
 // ============================= Variável de Serviço do Drive =============================
 
-var srv *drive.Service = getService()
+// The client used by the command-line interface. It is created by "runCli" once the command is known, so asking for help never requires authentication. Other accounts can be used at the same time through their own clients, see "newDriveClient".
+var defaultClient *driveClient
 
 // ============================== Chamada das funções criadas ==============================
 
 func main() {
-	parentFolderUrl := getGoDotEnvVariable("PARENT_FOLDER_URL")
-	
-	newFolder, err := createFolder("MyNewFolder", parentFolderUrl, true)
-	errorPrinter(err)
-	if newFolder != nil {
-		prettyPrinter(fmt.Sprintf("Folder ID: %s", newFolder.Id))
-	}
-
-	createdFile, err := createFileInsideOf(&drive.File{
-		Name: "Meu Arquivo",
-		MimeType: "application/vnd.google-apps.spreadsheet",
-		Parents: []string{newFolder.Id},
-	})
-	errorPrinter(err)
-	if createdFile != nil {
-		prettyPrinter(fmt.Sprintf("Created File ID: %s", createdFile.Id))
-	}
-	
-	filePath := getGoDotEnvVariable("FILE_PATH")
-	file, err := os.Open(filePath)
-	errorPrinter(err)
-	uploadedFile, err := uploadFiles(file, parentFolderUrl)
-	errorPrinter(err)
-	file.Close()
-	prettyPrinter( fmt.Sprintf("File Uploaded: %s", uploadedFile.Name) )
-	
-	files, err := getFolderInfos(parentFolderUrl)
-	errorPrinter(err)
-	for index, file := range files {
-		fmt.Printf(`
-		[%d] %s (%s)
-		-----------`, index, file.Name, file.Id)
-
-		if strings.Contains(strings.ToLower(file.Name), "grade") {
-			copiedFile, err := copyFileTo(file, newFolder.Id)
-			errorPrinter(err)
-			prettyPrinter(fmt.Sprintf("This is the copied file:\n%#v", copiedFile.Id))
-
-			targetFolderUrl := getGoDotEnvVariable("OTHER_FOLDER_URL")
-
-			movedFile, err := moveFileTo(parentFolderUrl, targetFolderUrl, file)
-			errorPrinter(err)
-			prettyPrinter(fmt.Sprintf("This is the moved file:\n%s", movedFile.Id))
-
-			downloadFiles(file, "C:\\dev", "pdf")
-		}
-
-		if strings.Contains(strings.ToLower(file.Name), "captura") {
-			permanentlyDeleteFile(file.Id)
-		}
-	}
-
-	emptyTrash()
-}
\ No newline at end of file
+	os.Exit(runCli(os.Args[1:]))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ========== This section is responsible to find drive items by their path ==========
+
+// Returned when a path cannot be resolved because a folder has more than one item with the same name.
+type ambiguousPathError struct {
+	Path  string
+	Name  string
+	Count int
+}
+
+func (err *ambiguousPathError) Error () string {
+	return fmt.Sprintf("the path %q is ambiguous: there is more than one item named %q, use its ID instead", err.Path, err.Name)
+}
+
+// Returned when an item of a path does not exist.
+type pathNotFoundError struct {
+	Path string
+	Name string
+}
+
+func (err *pathNotFoundError) Error () string {
+	return fmt.Sprintf("the path %q does not exist: %q was not found", err.Path, err.Name)
+}
+
+// Remembers the ID of each name already found inside a folder, so the same folders are not listed again and again. Each client has its own, because "root" and the folders that can be seen depend on the account.
+type pathCache struct {
+	sync.Mutex
+	ids map[string]string
+}
+
+// Checks if a reference is a path, like "/Reports/2024/Q1/grades.pdf", instead of a URL or ID.
+func isDrivePath (reference string) bool {
+	return strings.HasPrefix(reference, "/")
+}
+
+// Splits a path into the names of its items, ignoring empty parts like the ones in "/Reports//2024/".
+func splitDrivePath (path string) []string {
+	var names []string
+	for _, name := range strings.Split(path, "/") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Finds the ID of an item directly inside a folder by its name.
+func (client *driveClient) findChild (parentId string, name string, path string) (string, error) {
+	key := parentId + "/" + name
+
+	client.paths.Lock()
+	id, ok := client.paths.ids[key]
+	client.paths.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var ids []string
+	iterator := client.listFolder(context.Background(), parentId, listOptions{PageSize: 2, Fields: "id", Filter: nameEquals(name)})
+	for iterator.Next() && len(ids) < 2 {
+		ids = append(ids, iterator.File().Id)
+	}
+	if err := iterator.Err(); err != nil {
+		return "", err
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", &pathNotFoundError{Path: path, Name: name}
+	case 1:
+		client.paths.Lock()
+		client.paths.ids[key] = ids[0]
+		client.paths.Unlock()
+		return ids[0], nil
+	}
+
+	return "", &ambiguousPathError{Path: path, Name: name, Count: len(ids)}
+}
+
+// Returns the ID of the folder that paths start from.
+func (client *driveClient) getRootId () (string, error) {
+	if client.Root == "" || isDrivePath(client.Root) {
+		return "root", nil
+	}
+
+	return client.getFolderId(client.Root)
+}
+
+// This function returns the ID of the item at a path, like "/Reports/2024/Q1/grades.pdf". The path starts from the "Root" of the client, which is My Drive by default.
+//
+// When "createMissing" is true, every missing folder before the last item is created. The last item must always exist, use "ensureFolderPath" to create it too.
+func (client *driveClient) resolvePath (path string, createMissing bool) (string, error) {
+	names := splitDrivePath(path)
+	id, err := client.getRootId()
+	if err != nil {
+		return "", err
+	}
+
+	for index, name := range names {
+		childId, err := client.findChild(id, name, path)
+
+		if _, notFound := err.(*pathNotFoundError); notFound && createMissing && index < len(names)-1 {
+			childId, err = client.createPathFolder(name, id)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		id = childId
+	}
+
+	return id, nil
+}
+
+// This function returns the ID of the folder at a path, creating every folder that is missing, including the last one.
+func (client *driveClient) ensureFolderPath (path string) (string, error) {
+	names := splitDrivePath(path)
+	id, err := client.getRootId()
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		childId, err := client.findChild(id, name, path)
+		if _, notFound := err.(*pathNotFoundError); notFound {
+			childId, err = client.createPathFolder(name, id)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		id = childId
+	}
+
+	return id, nil
+}
+
+// Creates a folder of a path and remembers its ID.
+func (client *driveClient) createPathFolder (name string, parentId string) (string, error) {
+	folder, err := client.createFolder(name, parentId, true)
+	if err != nil {
+		return "", err
+	}
+
+	client.paths.Lock()
+	client.paths.ids[parentId+"/"+name] = folder.Id
+	client.paths.Unlock()
+
+	return folder.Id, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to share files and folders ==========
+
+// The roles that can be granted on a file. The organizer role only exists in shared drives.
+const (
+	readerRole    = "reader"
+	commenterRole = "commenter"
+	writerRole    = "writer"
+	organizerRole = "organizer"
+)
+
+// The kinds of grantees that can receive a permission.
+const (
+	userGrantee   = "user"
+	groupGrantee  = "group"
+	domainGrantee = "domain"
+	anyoneGrantee = "anyone"
+)
+
+// Who receives a permission. The address is the email of a user or group, or the name of a domain, like "example.com". It must be empty for "anyone", which means anyone with the link.
+type grantee struct {
+	Type    string
+	Address string
+}
+
+// Options used when sharing. When SuppressNotification is true, users and groups do not receive the notification email. Message is added to the notification email, when it is sent.
+type shareOptions struct {
+	SuppressNotification bool
+	Message              string
+}
+
+// Fields of a permission returned by the functions below.
+const permissionFields = "id, type, role, emailAddress, domain, displayName"
+
+// Checks if a role is one of the roles drive accepts.
+func validateRole (role string) error {
+	switch role {
+	case readerRole, commenterRole, writerRole, organizerRole:
+		return nil
+	}
+
+	return fmt.Errorf("%q is not a valid role, use %q, %q, %q or %q", role, readerRole, commenterRole, writerRole, organizerRole)
+}
+
+// Builds the permission that grants a role to a grantee, checking if the grantee makes sense.
+func newPermission (who grantee, role string) (*drive.Permission, error) {
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+
+	permission := &drive.Permission{Type: who.Type, Role: role}
+
+	switch who.Type {
+	case userGrantee, groupGrantee:
+		if who.Address == "" {
+			return nil, fmt.Errorf("an email address is required to share with a %s", who.Type)
+		}
+		permission.EmailAddress = who.Address
+	case domainGrantee:
+		if who.Address == "" {
+			return nil, fmt.Errorf("a domain name is required to share with a domain")
+		}
+		permission.Domain = who.Address
+	case anyoneGrantee:
+		if who.Address != "" {
+			return nil, fmt.Errorf("sharing with anyone does not take an address, but %q was given", who.Address)
+		}
+	default:
+		return nil, fmt.Errorf("%q is not a valid grantee type, use %q, %q, %q or %q", who.Type, userGrantee, groupGrantee, domainGrantee, anyoneGrantee)
+	}
+
+	return permission, nil
+}
+
+// This function grants a role on a file or folder to a user, group, domain or anyone with the link. You have to provide the file URL or ID, who receives the permission, the role and the share options.
+//
+// Please note that drive does not create a second permission for the same grantee, it updates the role of the existing one.
+func (client *driveClient) shareFile (fileUrl string, who grantee, role string, options shareOptions) (*drive.Permission, error) {
+	permission, err := newPermission(who, role)
+	if err != nil {
+		return nil, err
+	}
+
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	call := client.Service.Permissions.Create(fileId, permission).
+		Fields(permissionFields).
+		SupportsAllDrives(true)
+
+	if who.Type == userGrantee || who.Type == groupGrantee {
+		call = call.SendNotificationEmail(!options.SuppressNotification)
+		if !options.SuppressNotification && options.Message != "" {
+			call = call.EmailMessage(options.Message)
+		}
+	}
+
+	return call.Do()
+}
+
+// This function shares a folder and every file and subfolder inside of it, one by one. It is useful for shared drives and for files that do not inherit the folder permissions.
+//
+// Sharing keeps going when a single item fails. The return holds the error of every item that could not be shared, indexed by ID, so an empty map means that everything was shared.
+func (client *driveClient) shareFolderTree (folderUrl string, who grantee, role string, options shareOptions) map[string]error {
+	failures := make(map[string]error)
+	folderId, err := client.getFolderId(folderUrl)
+	if err != nil {
+		failures[folderUrl] = err
+		return failures
+	}
+
+	if _, err := client.shareFile(folderId, who, role, options); err != nil {
+		failures[folderId] = err
+	}
+
+	iterator := client.listFolder(context.Background(), folderId, listOptions{})
+	for iterator.Next() {
+		file := iterator.File()
+
+		if file.MimeType == "application/vnd.google-apps.folder" {
+			for id, err := range client.shareFolderTree(file.Id, who, role, options) {
+				failures[id] = err
+			}
+			continue
+		}
+
+		if _, err := client.shareFile(file.Id, who, role, options); err != nil {
+			failures[file.Id] = err
+		}
+	}
+	if err := iterator.Err(); err != nil {
+		failures[folderId] = err
+	}
+
+	return failures
+}
+
+// Returns every permission of a file or folder. You must provide the file URL or ID.
+func (client *driveClient) listPermissions (fileUrl string) ([]*drive.Permission, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var permissions []*drive.Permission
+	err = client.Service.Permissions.List(fileId).
+		Fields("nextPageToken, permissions(" + permissionFields + ")").
+		SupportsAllDrives(true).
+		Pages(context.Background(), func(permissionList *drive.PermissionList) error {
+			permissions = append(permissions, permissionList.Permissions...)
+			return nil
+		})
+
+	return permissions, err
+}
+
+// Searches the permissions of a file for the one that belongs to an email address or domain. If no permission is found, the return is a nil pointer.
+func (client *driveClient) getPermissionFor (fileUrl string, address string) (*drive.Permission, error) {
+	if address == "" {
+		return nil, fmt.Errorf("an email address or domain is required to find a permission")
+	}
+
+	permissions, err := client.listPermissions(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, permission := range permissions {
+		if permission.EmailAddress == address || permission.Domain == address {
+			return permission, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// This function changes the role of an existing permission. You must provide the file URL or ID, the permission ID and the new role.
+func (client *driveClient) updatePermissionRole (fileUrl string, permissionId string, role string) (*drive.Permission, error) {
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Service.Permissions.Update(fileId, permissionId, &drive.Permission{Role: role}).
+		Fields(permissionFields).
+		SupportsAllDrives(true).
+		Do()
+}
+
+// This function removes a permission from a file or folder, revoking the access of its grantee. You must provide the file URL or ID and the permission ID.
+func (client *driveClient) revokePermission (fileUrl string, permissionId string) error {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return err
+	}
+
+	return client.Service.Permissions.Delete(fileId, permissionId).SupportsAllDrives(true).Do()
+}
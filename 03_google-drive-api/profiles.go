@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// ========== This section is responsible for the accounts the program can use ==========
+
+// Where the profiles are saved, next to the credentials.
+const profilesPath = "credentials/profiles.json"
+
+// The environment variable that chooses the profile when none is given. The profile can also be chosen by the "profile" setting.
+const profileVariable = "GOOGLE_PROFILE"
+
+// The profile used by "getDefaultClient". When empty, the "profile" setting, or the default profile of the file, is used.
+var activeProfile string
+
+// An account the program can use, like "personal", "team" or "service". Every field is optional, and the ones left empty come from the configuration, see "getAuthConfig".
+//
+// Each profile keeps its own token in "credentials/token-<name>.json" unless TokenFile, or a "token_file" set in the configuration, says otherwise, so the accounts never overwrite each other. TokenKeyVariable is the environment variable that holds the key of the "encrypted-file" store. DefaultFolder is used by the commands when no folder is given.
+type profile struct {
+	Name             string   `json:"-"`
+	AuthMethod       string   `json:"authMethod,omitempty"`
+	CredentialsFile  string   `json:"credentialsFile,omitempty"`
+	TokenStore       string   `json:"tokenStore,omitempty"`
+	TokenFile        string   `json:"tokenFile,omitempty"`
+	TokenKeyVariable string   `json:"tokenKeyVariable,omitempty"`
+	Subject          string   `json:"subject,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	DefaultFolder    string   `json:"defaultFolder,omitempty"`
+}
+
+// The content of the profiles file, like:
+//
+//	{
+//		"default": "personal",
+//		"profiles": {
+//			"personal": {"credentialsFile": "credentials/creds.json"},
+//			"service": {"authMethod": "service-account", "credentialsFile": "credentials/robot.json", "defaultFolder": "https://drive.google.com/drive/folders/<id>"}
+//		}
+//	}
+type profilesFile struct {
+	Default  string             `json:"default,omitempty"`
+	Profiles map[string]profile `json:"profiles"`
+}
+
+// Reads the profiles file. A missing file is not an error, it just has no profiles.
+func readProfiles (path string) (*profilesFile, error) {
+	profiles := &profilesFile{Profiles: make(map[string]profile)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, profiles); err != nil {
+		return nil, fmt.Errorf("unable to read the profiles in %q: %w", path, err)
+	}
+	if profiles.Profiles == nil {
+		profiles.Profiles = make(map[string]profile)
+	}
+
+	return profiles, nil
+}
+
+// Returns the names of every profile, in alphabetical order.
+func (profiles *profilesFile) names () []string {
+	var names []string
+	for name := range profiles.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// This function returns a profile by its name. When the name is empty, the "profile" setting is used, then the default profile of the file.
+//
+// When no profile is chosen at all, the returned profile is empty, and the program works with the configuration alone, as if there were no profiles.
+func getProfile (name string) (profile, error) {
+	if name == "" {
+		settings, err := getSettings()
+		if err != nil {
+			return profile{}, err
+		}
+		name = settings.String("profile")
+	}
+
+	profiles, err := readProfiles(profilesPath)
+	if err != nil {
+		return profile{}, err
+	}
+	if name == "" {
+		name = profiles.Default
+	}
+	if name == "" {
+		return profile{}, nil
+	}
+
+	found, ok := profiles.Profiles[name]
+	if !ok {
+		if len(profiles.Profiles) == 0 {
+			return profile{}, fmt.Errorf("there is no profile named %q, the file %q has no profiles", name, profilesPath)
+		}
+		return profile{}, fmt.Errorf("there is no profile named %q, use one of: %s", name, strings.Join(profiles.names(), ", "))
+	}
+	found.Name = name
+
+	return found, nil
+}
+
+// Returns the authentication settings of a profile, filling what it leaves empty with the configuration.
+func (chosen profile) authConfig () (authConfig, error) {
+	config, err := getAuthConfig()
+	if err != nil {
+		return config, err
+	}
+
+	if chosen.AuthMethod != "" {
+		config.Method = chosen.AuthMethod
+		if chosen.CredentialsFile == "" && settings.String("credentials_file") == "" {
+			config.CredentialsFile = defaultCredentialsFile(config.Method)
+		}
+	}
+	if chosen.CredentialsFile != "" {
+		config.CredentialsFile = chosen.CredentialsFile
+	}
+	if chosen.TokenStore != "" {
+		config.TokenStore = chosen.TokenStore
+	}
+	if chosen.TokenFile != "" {
+		config.TokenFile = chosen.TokenFile
+	} else if chosen.Name != "" && settings.Source("token_file") == "default" {
+		config.TokenFile = fmt.Sprintf("credentials/token-%s.json", chosen.Name)
+	}
+	if chosen.TokenKeyVariable != "" {
+		config.TokenKey = os.Getenv(chosen.TokenKeyVariable)
+	}
+	if chosen.Subject != "" {
+		config.Subject = chosen.Subject
+	}
+	if len(chosen.Scopes) > 0 {
+		config.Scopes = chosen.Scopes
+	}
+
+	return config, nil
+}
+
+// A drive client signed in with a profile. Many clients can live in the same process, one for each account, and every helper of the program is a method of the client, so they never share a service.
+//
+// Root is the folder that paths like "/Reports/2024" start from. "root" is the user's My Drive, but any folder or shared drive ID can be used. Cache is the metadata cache of the account, nil when it is disabled, see "openMetadataCache".
+type driveClient struct {
+	Profile       string
+	Service       *drive.Service
+	HTTPClient    *http.Client
+	DefaultFolder string
+	Root          string
+	Cache         *metadataCache
+	paths         *pathCache
+}
+
+// This function creates a drive client for a profile. You have to provide the profile name, and an empty name picks the profile as in "getProfile".
+func newDriveClient (profileName string) (*driveClient, error) {
+	ctx := context.Background()
+
+	chosen, err := getProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := chosen.authConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenSource, err := newTokenSource(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := getClient(tokenSource)
+
+	service, err := drive.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+
+	return &driveClient{
+		Profile: chosen.Name,
+		Service: service,
+		HTTPClient: httpClient,
+		DefaultFolder: chosen.DefaultFolder,
+		Root: settings.String("root"),
+		paths: &pathCache{ids: make(map[string]string)},
+	}, nil
+}
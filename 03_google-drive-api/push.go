@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to receive push notifications from drive ==========
+
+// The headers that drive sends with every notification.
+const (
+	channelIdHeader      = "X-Goog-Channel-ID"
+	channelTokenHeader   = "X-Goog-Channel-Token"
+	channelExpiresHeader = "X-Goog-Channel-Expiration"
+	messageNumberHeader  = "X-Goog-Message-Number"
+	resourceIdHeader     = "X-Goog-Resource-ID"
+	resourceUriHeader    = "X-Goog-Resource-URI"
+	resourceStateHeader  = "X-Goog-Resource-State"
+	changedHeader        = "X-Goog-Changed"
+)
+
+// A notification sent by drive to a watch channel.
+//
+// State is what happened to the resource: "sync" when the channel is created, then "add", "remove", "update", "trash", "untrash" for files, or "change" for the changes feed. Changed details an update, like "content", "parents" or "permissions".
+type pushEvent struct {
+	ChannelId     string    `json:"channelId"`
+	ResourceId    string    `json:"resourceId"`
+	ResourceUri   string    `json:"resourceUri,omitempty"`
+	State         string    `json:"state"`
+	Changed       []string  `json:"changed,omitempty"`
+	MessageNumber int64     `json:"messageNumber,omitempty"`
+	Expiration    time.Time `json:"expiration,omitempty"`
+}
+
+// A channel registered by the receiver. When FileId is empty, the channel watches the changes feed instead of a single file.
+type watchChannel struct {
+	Channel *drive.Channel
+	FileId  string
+	Address string
+	TTL     time.Duration
+}
+
+// Receives the notifications that drive sends to the channels registered with "WatchFile" and "WatchChanges". It is an "http.Handler", so it can be served by any HTTP server, or by "ListenAndServe".
+//
+// Every notification must carry the token of its channel, otherwise it is rejected. The "sync" message that drive sends when a channel is created is acknowledged without calling the handler.
+type pushReceiver struct {
+	mutex    sync.Mutex
+	client   *driveClient
+	channels map[string]*watchChannel
+	tokens   map[string]string
+	handler  func(pushEvent)
+}
+
+// Creates a push receiver. The handler is called with every valid notification, and should return quickly, because drive waits for the answer.
+func (client *driveClient) newPushReceiver (handler func(pushEvent)) *pushReceiver {
+	return &pushReceiver{
+		client: client,
+		channels: make(map[string]*watchChannel),
+		tokens: make(map[string]string),
+		handler: handler,
+	}
+}
+
+// Returns a random value, used both as channel ID and as channel token.
+func randomChannelValue () (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+// Accepts the notifications of a channel that was created somewhere else, as long as they carry the given token.
+func (receiver *pushReceiver) TrustChannel (channelId string, token string) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+
+	receiver.tokens[channelId] = token
+}
+
+// Asks drive to send a notification to the address every time a file changes. You have to provide the file URL or ID, the public HTTPS address where the receiver is served and how long the channel should live. Drive may shorten that time.
+func (receiver *pushReceiver) WatchFile (fileUrl string, address string, ttl time.Duration) (*drive.Channel, error) {
+	fileId, err := receiver.client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return receiver.register(&watchChannel{FileId: fileId, Address: address, TTL: ttl})
+}
+
+// Asks drive to send a notification to the address every time anything changes in the user's drive. It pairs well with a "changesWatcher", whose "Poll" can be called on each notification.
+func (receiver *pushReceiver) WatchChanges (address string, ttl time.Duration) (*drive.Channel, error) {
+	return receiver.register(&watchChannel{Address: address, TTL: ttl})
+}
+
+// Creates the channel in drive and stores its token.
+func (receiver *pushReceiver) register (watched *watchChannel) (*drive.Channel, error) {
+	channelId, err := randomChannelValue()
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomChannelValue()
+	if err != nil {
+		return nil, err
+	}
+
+	request := &drive.Channel{
+		Id: channelId,
+		Type: "web_hook",
+		Address: watched.Address,
+		Token: token,
+		Expiration: time.Now().Add(watched.TTL).UnixNano() / int64(time.Millisecond),
+	}
+
+	var channel *drive.Channel
+	if watched.FileId != "" {
+		channel, err = receiver.client.Service.Files.Watch(watched.FileId, request).SupportsAllDrives(true).Do()
+	} else {
+		var startToken *drive.StartPageToken
+		startToken, err = receiver.client.Service.Changes.GetStartPageToken().SupportsAllDrives(true).Do()
+		if err == nil {
+			channel, err = receiver.client.Service.Changes.Watch(startToken.StartPageToken, request).
+				IncludeItemsFromAllDrives(true).
+				SupportsAllDrives(true).
+				Do()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Drive does not send the token back, it has to be kept from the request.
+	channel.Token = token
+	watched.Channel = channel
+
+	receiver.mutex.Lock()
+	receiver.channels[channel.Id] = watched
+	receiver.tokens[channel.Id] = token
+	receiver.mutex.Unlock()
+
+	return channel, nil
+}
+
+// Stops a channel, so drive stops sending its notifications.
+func (receiver *pushReceiver) Stop (channelId string) error {
+	receiver.mutex.Lock()
+	watched, ok := receiver.channels[channelId]
+	delete(receiver.channels, channelId)
+	delete(receiver.tokens, channelId)
+	receiver.mutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("there is no channel with ID %q", channelId)
+	}
+
+	return receiver.client.Service.Channels.Stop(&drive.Channel{
+		Id: watched.Channel.Id,
+		ResourceId: watched.Channel.ResourceId,
+	}).Do()
+}
+
+// Stops every channel registered by the receiver. It keeps going when a channel fails to stop, and returns the last error.
+func (receiver *pushReceiver) StopAll () error {
+	receiver.mutex.Lock()
+	var ids []string
+	for id := range receiver.channels {
+		ids = append(ids, id)
+	}
+	receiver.mutex.Unlock()
+
+	var lastErr error
+	for _, id := range ids {
+		if err := receiver.Stop(id); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// Replaces the channels that expire within the margin by new ones, checking every minute until the context is cancelled.
+//
+// The new channel is created before the old one is stopped, so no notification is lost in between. Errors are printed and the renewal is tried again on the next check.
+func (receiver *pushReceiver) KeepRenewed (ctx context.Context, margin time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		receiver.renewExpiring(margin)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Renews every channel that expires within the margin.
+func (receiver *pushReceiver) renewExpiring (margin time.Duration) {
+	limit := time.Now().Add(margin)
+
+	receiver.mutex.Lock()
+	var expiring []*watchChannel
+	for _, watched := range receiver.channels {
+		if millisecondsToTime(watched.Channel.Expiration).Before(limit) {
+			expiring = append(expiring, watched)
+		}
+	}
+	receiver.mutex.Unlock()
+
+	for _, watched := range expiring {
+		renewed := &watchChannel{FileId: watched.FileId, Address: watched.Address, TTL: watched.TTL}
+		if _, err := receiver.register(renewed); err != nil {
+			errorPrinter(err)
+			continue
+		}
+		errorPrinter(receiver.Stop(watched.Channel.Id))
+	}
+}
+
+// Converts the milliseconds used by drive channels to a time.
+func millisecondsToTime (milliseconds int64) time.Time {
+	return time.Unix(0, milliseconds*int64(time.Millisecond))
+}
+
+// Validates a notification and turns its headers into an event. Unknown channels are answered with 404 and wrong tokens with 401.
+func (receiver *pushReceiver) ServeHTTP (w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channelId := req.Header.Get(channelIdHeader)
+
+	receiver.mutex.Lock()
+	token, ok := receiver.tokens[channelId]
+	receiver.mutex.Unlock()
+
+	if !ok {
+		http.Error(w, "unknown channel", http.StatusNotFound)
+		return
+	}
+	received := req.Header.Get(channelTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(received)) != 1 {
+		http.Error(w, "invalid channel token", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := decodePushEvent(req.Header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if event.State != "sync" {
+		receiver.handler(event)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// This function serves the receiver on a local address, like "127.0.0.1:8080", until the context is cancelled. You have to provide the context and the address.
+//
+// Drive only sends notifications to public HTTPS addresses, so the local address is usually reached through a reverse proxy or a tunnel. The return is nil when the server stops because the context was cancelled.
+func (receiver *pushReceiver) ListenAndServe (ctx context.Context, address string) error {
+	server := &http.Server{Addr: address, Handler: receiver}
+
+	stopped := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return <-stopped
+}
+
+// Reads the headers of a notification.
+func decodePushEvent (header http.Header) (pushEvent, error) {
+	event := pushEvent{
+		ChannelId: header.Get(channelIdHeader),
+		ResourceId: header.Get(resourceIdHeader),
+		ResourceUri: header.Get(resourceUriHeader),
+		State: header.Get(resourceStateHeader),
+	}
+
+	if event.State == "" {
+		return event, fmt.Errorf("the %s header is missing", resourceStateHeader)
+	}
+
+	if changed := header.Get(changedHeader); changed != "" {
+		for _, item := range strings.Split(changed, ",") {
+			event.Changed = append(event.Changed, strings.TrimSpace(item))
+		}
+	}
+
+	if number := header.Get(messageNumberHeader); number != "" {
+		messageNumber, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return event, fmt.Errorf("invalid %s header: %w", messageNumberHeader, err)
+		}
+		event.MessageNumber = messageNumber
+	}
+
+	if expiration := header.Get(channelExpiresHeader); expiration != "" {
+		expiresAt, err := http.ParseTime(expiration)
+		if err != nil {
+			return event, fmt.Errorf("invalid %s header: %w", channelExpiresHeader, err)
+		}
+		event.Expiration = expiresAt
+	}
+
+	return event, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Starts a receiver that trusts a single channel, and returns it along with the events it handled.
+func newTestReceiver (t *testing.T) (*httptest.Server, *[]pushEvent) {
+	events := &[]pushEvent{}
+	receiver := (&driveClient{}).newPushReceiver(func(event pushEvent) {
+		*events = append(*events, event)
+	})
+	receiver.TrustChannel("channel-1", "secret")
+
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	return server, events
+}
+
+// Sends a notification with the given headers and returns the status code of the answer.
+func sendNotification (t *testing.T, server *httptest.Server, headers map[string]string) int {
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	return res.StatusCode
+}
+
+func TestPushReceiverAcceptsValidToken (t *testing.T) {
+	server, events := newTestReceiver(t)
+
+	status := sendNotification(t, server, map[string]string{
+		channelIdHeader: "channel-1",
+		channelTokenHeader: "secret",
+		resourceIdHeader: "resource-1",
+		resourceStateHeader: "update",
+		changedHeader: "content, parents",
+		messageNumberHeader: "7",
+		channelExpiresHeader: "Tue, 19 Nov 2030 01:13:52 GMT",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	if len(*events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(*events))
+	}
+	event := (*events)[0]
+	if event.ChannelId != "channel-1" || event.ResourceId != "resource-1" || event.State != "update" || event.MessageNumber != 7 {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if len(event.Changed) != 2 || event.Changed[0] != "content" || event.Changed[1] != "parents" {
+		t.Errorf("unexpected changed list: %v", event.Changed)
+	}
+	if event.Expiration.Year() != 2030 {
+		t.Errorf("unexpected expiration: %v", event.Expiration)
+	}
+}
+
+func TestPushReceiverRejectsInvalidChannels (t *testing.T) {
+	server, events := newTestReceiver(t)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"wrong token", map[string]string{channelIdHeader: "channel-1", channelTokenHeader: "guess", resourceStateHeader: "update"}, http.StatusUnauthorized},
+		{"missing token", map[string]string{channelIdHeader: "channel-1", resourceStateHeader: "update"}, http.StatusUnauthorized},
+		{"unknown channel", map[string]string{channelIdHeader: "channel-2", channelTokenHeader: "secret", resourceStateHeader: "update"}, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		if status := sendNotification(t, server, test.headers); status != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, status)
+		}
+	}
+	if len(*events) != 0 {
+		t.Errorf("expected no events, got %d", len(*events))
+	}
+}
+
+func TestPushReceiverAcknowledgesSync (t *testing.T) {
+	server, events := newTestReceiver(t)
+
+	status := sendNotification(t, server, map[string]string{
+		channelIdHeader: "channel-1",
+		channelTokenHeader: "secret",
+		resourceStateHeader: "sync",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if len(*events) != 0 {
+		t.Errorf("expected no events, got %d", len(*events))
+	}
+}
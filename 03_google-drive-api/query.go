@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ========== This section is responsible to build drive search queries ==========
+
+// A drive search query, like "name = 'grades' and trashed = false". Build it with the functions below instead of formatting strings, so every value is escaped correctly.
+//
+// An empty query matches everything and is ignored when combined with others.
+type driveQuery string
+
+// Escapes a value to be used between single quotes inside a query, so names like "Pedro's files" do not break the search.
+func escapeQueryValue (value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
+// Returns a value between single quotes, already escaped.
+func quoteQueryValue (value string) string {
+	return "'" + escapeQueryValue(value) + "'"
+}
+
+// Matches files whose name is exactly the one given.
+func nameEquals (name string) driveQuery {
+	return driveQuery("name = " + quoteQueryValue(name))
+}
+
+// Matches files whose name contains the text given. Please note that drive only matches the text as a prefix of the words in the name.
+func nameContains (text string) driveQuery {
+	return driveQuery("name contains " + quoteQueryValue(text))
+}
+
+// Matches files of the given MIME type, like "application/vnd.google-apps.folder".
+func mimeTypeEquals (mimeType string) driveQuery {
+	return driveQuery("mimeType = " + quoteQueryValue(mimeType))
+}
+
+// Matches files that are directly inside the given folder ID.
+func hasParent (folderId string) driveQuery {
+	return driveQuery(quoteQueryValue(folderId) + " in parents")
+}
+
+// Matches files that are, or are not, in the trash.
+func isTrashed (trashed bool) driveQuery {
+	return driveQuery(fmt.Sprintf("trashed = %t", trashed))
+}
+
+// Matches files that are, or are not, starred.
+func isStarred (starred bool) driveQuery {
+	return driveQuery(fmt.Sprintf("starred = %t", starred))
+}
+
+// Matches files modified after the given time.
+func modifiedAfter (t time.Time) driveQuery {
+	return driveQuery("modifiedTime > " + quoteQueryValue(t.UTC().Format(time.RFC3339)))
+}
+
+// Matches files modified before the given time.
+func modifiedBefore (t time.Time) driveQuery {
+	return driveQuery("modifiedTime < " + quoteQueryValue(t.UTC().Format(time.RFC3339)))
+}
+
+// Matches files whose name, description or content contains the text given.
+func fullTextContains (text string) driveQuery {
+	return driveQuery("fullText contains " + quoteQueryValue(text))
+}
+
+// Matches files that have a public custom property with the given key and value.
+func hasProperty (key string, value string) driveQuery {
+	return driveQuery(fmt.Sprintf("properties has { key=%s and value=%s }", quoteQueryValue(key), quoteQueryValue(value)))
+}
+
+// Matches files owned by the given email address.
+func ownedBy (email string) driveQuery {
+	return driveQuery(quoteQueryValue(email) + " in owners")
+}
+
+// Joins the non-empty queries with the given operator, wrapping each one in parentheses when there is more than one.
+func joinQueries (operator string, queries []driveQuery) driveQuery {
+	var parts []string
+	for _, query := range queries {
+		if query != "" {
+			parts = append(parts, string(query))
+		}
+	}
+
+	if len(parts) == 1 {
+		return driveQuery(parts[0])
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return driveQuery("(" + strings.Join(parts, ") "+operator+" (") + ")")
+}
+
+// Matches files that match every one of the queries.
+func allOf (queries ...driveQuery) driveQuery {
+	return joinQueries("and", queries)
+}
+
+// Matches files that match at least one of the queries.
+func anyOf (queries ...driveQuery) driveQuery {
+	return joinQueries("or", queries)
+}
+
+// Matches files that do not match the query.
+func not (query driveQuery) driveQuery {
+	if query == "" {
+		return ""
+	}
+
+	return driveQuery("not (" + string(query) + ")")
+}
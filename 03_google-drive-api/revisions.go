@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// ========== This section is responsible for the revision history of files ==========
+
+// Fields of a revision returned by the functions below.
+const revisionFields = "id, mimeType, modifiedTime, size, md5Checksum, keepForever, exportLinks, lastModifyingUser(displayName, emailAddress)"
+
+// Returns every revision of a file, from the oldest to the newest. You must provide the file URL or ID.
+func (client *driveClient) listRevisions (fileUrl string) ([]*drive.Revision, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []*drive.Revision
+	err = client.Service.Revisions.List(fileId).
+		Fields("nextPageToken, revisions(" + revisionFields + ")").
+		Pages(context.Background(), func(revisionList *drive.RevisionList) error {
+			revisions = append(revisions, revisionList.Revisions...)
+			return nil
+		})
+
+	return revisions, err
+}
+
+// Prints the revisions of a file with their author, time and size. Like the rest of the program, the revisions are printed as JSON when "jsonOutput" is true, and to "messageOutput" otherwise.
+func printRevisions (revisions []*drive.Revision) error {
+	if jsonOutput {
+		if revisions == nil {
+			revisions = []*drive.Revision{}
+		}
+		return printJson(revisions)
+	}
+
+	for _, revision := range revisions {
+		author := "unknown"
+		if revision.LastModifyingUser != nil {
+			author = revision.LastModifyingUser.DisplayName
+		}
+
+		pinned := ""
+		if revision.KeepForever {
+			pinned = " (kept forever)"
+		}
+
+		fmt.Fprintf(messageOutput, "\n\t[%s] %s by %s, %d bytes%s", revision.Id, revision.ModifiedTime, author, revision.Size, pinned)
+	}
+
+	return nil
+}
+
+// Returns the content of a revision. Revisions of google workspace files are exported to the given format, as in "downloadFiles", every other revision is downloaded as it is.
+//
+// Do not forget to close the content afterwards.
+func (client *driveClient) getRevisionContent (fileId string, revisionId string, fileFormat string) (io.ReadCloser, error) {
+	revision, err := client.Service.Revisions.Get(fileId, revisionId).Fields(revisionFields).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(revision.MimeType, "application/vnd.google-apps.") {
+		data, err := client.Service.Revisions.Get(fileId, revisionId).Download()
+		if err != nil {
+			return nil, err
+		}
+		return data.Body, nil
+	}
+
+	exportMimeType, err := getExportMimeType(revision.MimeType, fileFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	link, ok := revision.ExportLinks[exportMimeType]
+	if !ok {
+		return nil, &exportFormatError{MimeType: revision.MimeType, Format: fileFormat}
+	}
+
+	res, err := client.HTTPClient.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	if err := googleapi.CheckResponse(res); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// This function downloads a specific revision of a file to the exact local path given. You have to provide the file URL or ID, the revision ID, the format used for google workspace files and the local file path.
+func (client *driveClient) downloadRevision (fileUrl string, revisionId string, fileFormat string, localFilePath string) error {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return err
+	}
+
+	data, err := client.getRevisionContent(fileId, revisionId, fileFormat)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	downloadedFile, err := os.Create(localFilePath)
+	if err != nil {
+		return err
+	}
+	defer downloadedFile.Close()
+
+	_, err = io.Copy(downloadedFile, data)
+	return err
+}
+
+// This function pins or unpins a revision. Pinned revisions are kept forever, otherwise drive removes old revisions of binary files after some time.
+func (client *driveClient) keepRevisionForever (fileUrl string, revisionId string, keep bool) (*drive.Revision, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Service.Revisions.Update(fileId, revisionId, &drive.Revision{
+		KeepForever: keep,
+		ForceSendFields: []string{"KeepForever"},
+	}).Fields(revisionFields).Do()
+}
+
+// This function permanently deletes a revision of a file.
+//
+// Please note that drive does not delete the last remaining revision, nor revisions of google workspace files.
+func (client *driveClient) deleteRevision (fileUrl string, revisionId string) error {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return err
+	}
+
+	return client.Service.Revisions.Delete(fileId, revisionId).Do()
+}
+
+// This function deletes the old revisions of a file, keeping only the newest ones. You have to provide the file URL or ID and how many revisions to keep.
+//
+// Revisions kept forever are never deleted. The return is the list of deleted revisions.
+func (client *driveClient) deleteOldRevisions (fileUrl string, keepLatest int) ([]*drive.Revision, error) {
+	if keepLatest < 1 {
+		keepLatest = 1
+	}
+
+	revisions, err := client.listRevisions(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []*drive.Revision
+	for index := 0; index < len(revisions)-keepLatest; index++ {
+		revision := revisions[index]
+		if revision.KeepForever {
+			continue
+		}
+
+		if err := client.deleteRevision(fileUrl, revision.Id); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, revision)
+	}
+
+	return deleted, nil
+}
+
+// This function restores an older revision of a file, uploading its content again as the newest revision. The revision being restored, and every revision after it, stay in the history.
+//
+// Please note that only binary files can be restored this way. Google workspace files must be restored from the version history in the browser.
+func (client *driveClient) restoreRevision (fileUrl string, revisionId string) (*drive.File, error) {
+	fileId, err := client.getFolderId(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := client.Service.Revisions.Get(fileId, revisionId).Fields("id, mimeType").Do()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(revision.MimeType, "application/vnd.google-apps.") {
+		return nil, fmt.Errorf("revisions of %q files cannot be restored by upload, use the version history in the browser", revision.MimeType)
+	}
+
+	data, err := client.Service.Revisions.Get(fileId, revisionId).Download()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Body.Close()
+
+	return client.Service.Files.Update(fileId, &drive.File{}).
+		SupportsAllDrives(true).
+		Media(data.Body, googleapi.ContentType(revision.MimeType)).
+		Do()
+}
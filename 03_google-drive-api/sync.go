@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to sync local folders with drive ==========
+
+// Keeps track of what happened to every item during a sync. Each slice holds the local paths of the items.
+type syncSummary struct {
+	Created []string
+	Updated []string
+	Skipped []string
+	Failed  map[string]error
+}
+
+// Prints the totals of a sync, followed by every item that failed and the reason why.
+func (summary *syncSummary) print () {
+	prettyPrinter(fmt.Sprintf("Sync finished!\nCreated: %d\nUpdated: %d\nSkipped: %d\nFailed: %d",
+		len(summary.Created), len(summary.Updated), len(summary.Skipped), len(summary.Failed)))
+
+	for path, err := range summary.Failed {
+		errorPrinter(fmt.Errorf("%s: %w", path, err))
+	}
+}
+
+// Returns the files inside a drive folder indexed by name, along with the fields needed to compare them with local files.
+func (client *driveClient) getSyncTargets (folderId string) (map[string]*drive.File, error) {
+	files, err := client.getFolderChildren(folderId, "id, name, mimeType, size, md5Checksum")
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]*drive.File)
+	for _, file := range files {
+		targets[file.Name] = file
+	}
+
+	return targets, nil
+}
+
+// Calculates the md5 checksum of a local file, in the same format drive uses for the "md5Checksum" field.
+func localMd5Checksum (path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// This function replaces the content of a drive file with a local file. The drive file keeps its ID, name and parents, only a new revision is created.
+func (client *driveClient) updateFileContent (fileId string, file *os.File) (*drive.File, error) {
+	updatedFile, err := client.Service.Files.Update(fileId, &drive.File{}).SupportsAllDrives(true).Media(file).Do()
+	return updatedFile, err
+}
+
+// This function mirrors a local folder inside a drive parent. You have to provide the local folder path and the drive folder URL or ID.
+//
+// Every subfolder that does not exist in drive is created with "createFolder". Files that already exist with the same size and md5 checksum are skipped, files that changed are uploaded again as a new revision and new files are uploaded with "uploadFiles". Local files named like a drive folder or a google workspace file are reported as failures, since they cannot replace it.
+//
+// Please note that this function *DOES NOT* delete anything in drive. Files that only exist in drive are left untouched.
+func (client *driveClient) syncFolder (localPath string, parentUrl string) (*syncSummary, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%q is not a directory", localPath)
+	}
+
+	parentId, err := client.getFolderId(parentUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &syncSummary{Failed: make(map[string]error)}
+	err = client.syncDirectory(localPath, parentId, summary)
+
+	return summary, err
+}
+
+// Syncs the content of a single local directory into a drive folder, calling itself for every subdirectory.
+//
+// Only errors that prevent the directory from being read are returned, errors on single items are stored in the summary so the sync keeps going.
+func (client *driveClient) syncDirectory (localPath string, folderId string, summary *syncSummary) error {
+	entries, err := os.ReadDir(localPath)
+	if err != nil {
+		return err
+	}
+
+	targets, err := client.getSyncTargets(folderId)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(localPath, entry.Name())
+		target, exists := targets[entry.Name()]
+
+		if entry.IsDir() {
+			if !exists || target.MimeType != "application/vnd.google-apps.folder" {
+				target, err = client.createFolder(entry.Name(), folderId, true)
+				if err != nil {
+					summary.Failed[entryPath] = err
+					continue
+				}
+				summary.Created = append(summary.Created, entryPath)
+			}
+
+			if err := client.syncDirectory(entryPath, target.Id, summary); err != nil {
+				summary.Failed[entryPath] = err
+			}
+			continue
+		}
+
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		if err := client.syncFile(entryPath, folderId, target, summary); err != nil {
+			summary.Failed[entryPath] = err
+		}
+	}
+
+	return nil
+}
+
+// Uploads a single local file, updates the drive file when its content changed or skips it when both are the same.
+func (client *driveClient) syncFile (localPath string, folderId string, target *drive.File, summary *syncSummary) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if target == nil {
+		if _, err := client.uploadFiles(file, folderId); err != nil {
+			return err
+		}
+		summary.Created = append(summary.Created, localPath)
+		return nil
+	}
+
+	// Folders and google workspace files have no content to replace, so the name is taken by something that is not a copy of the local file.
+	if isWorkspaceFile(target) {
+		return fmt.Errorf("drive already has an item of type %q named %q in the folder, it was left untouched", target.MimeType, target.Name)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	checksum, err := localMd5Checksum(localPath)
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Size() == target.Size && checksum == target.Md5Checksum {
+		summary.Skipped = append(summary.Skipped, localPath)
+		return nil
+	}
+
+	if _, err := client.updateFileContent(target.Id, file); err != nil {
+		return err
+	}
+	summary.Updated = append(summary.Updated, localPath)
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to run many transfers at the same time ==========
+
+// Limits how many requests per second are sent to drive. Every request made through the HTTP client of any "driveClient" waits for its turn, no matter which goroutine sends it.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Changes the limit of requests per second. Zero or a negative value removes the limit.
+func (limiter *rateLimiter) setRate (requestsPerSecond float64) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if requestsPerSecond <= 0 {
+		limiter.interval = 0
+		return
+	}
+	limiter.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+}
+
+// Blocks until the next request is allowed or the context is cancelled.
+func (limiter *rateLimiter) wait (ctx context.Context) error {
+	limiter.mutex.Lock()
+	if limiter.interval == 0 {
+		limiter.mutex.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	turn := limiter.next
+	if turn.Before(now) {
+		turn = now
+	}
+	limiter.next = turn.Add(limiter.interval)
+	limiter.mutex.Unlock()
+
+	timer := time.NewTimer(turn.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HTTP transport that waits for the rate limiter before sending each request.
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (transport *rateLimitedTransport) RoundTrip (req *http.Request) (*http.Response, error) {
+	if err := transport.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	return transport.base.RoundTrip(req)
+}
+
+// The limit of requests per second used when the "requests_per_second" setting is not given. Drive starts answering with "rateLimitExceeded" when a single user sends much more than 10 requests per second.
+const defaultRequestsPerSecond = 10
+
+// Global limiter shared by every request sent to drive. Its rate is a setting of the whole process, the "requests_per_second" setting, and it is "defaultRequestsPerSecond" until the configuration is loaded.
+var requestLimiter = &rateLimiter{interval: time.Second / defaultRequestsPerSecond}
+
+// Number of workers used when none is given.
+const defaultTransferWorkers = 8
+
+// The operations that a transfer queue is able to run.
+type transferKind int
+
+const (
+	copyTransfer transferKind = iota
+	moveTransfer
+	uploadTransfer
+	downloadTransfer
+)
+
+func (kind transferKind) String () string {
+	switch kind {
+	case copyTransfer:
+		return "copy"
+	case moveTransfer:
+		return "move"
+	case uploadTransfer:
+		return "upload"
+	case downloadTransfer:
+		return "download"
+	}
+	return "unknown"
+}
+
+// A single operation submitted to a transfer queue. Which fields are used depends on the kind:
+//
+//  - copy: the drive File is copied to the Target folder. Force copies it even if the folder already has a file with the same name and type;
+//  - move: the drive File is moved from the Source folder to the Target folder. An empty Source moves it out of every folder it is in;
+//  - upload: the local file in Source is uploaded to the Target folder;
+//  - download: the drive File is downloaded to the local Target folder, using Format as in "downloadFiles".
+type transferJob struct {
+	Kind   transferKind
+	File   *drive.File
+	Source string
+	Target string
+	Format string
+	Force  bool
+}
+
+// The outcome of a transfer job. File is the file returned by drive, when the operation returns one.
+type transferResult struct {
+	Job      transferJob
+	File     *drive.File
+	Err      error
+	Duration time.Duration
+}
+
+// Aggregate numbers of every job that went through a transfer queue.
+type transferStats struct {
+	Submitted int
+	Succeeded int
+	Failed    int
+	Elapsed   time.Duration
+}
+
+// Runs transfer jobs with a bounded number of workers. Jobs are sent with "Submit" and their results come back, in completion order, on the "Results" channel.
+type transferQueue struct {
+	client    *driveClient
+	jobs      chan transferJob
+	results   chan transferResult
+	workers   sync.WaitGroup
+	mutex     sync.Mutex
+	stats     transferStats
+	startedAt time.Time
+}
+
+// Creates a transfer queue and starts its workers. The queue does not change the rate of requests, the workers share the limit of the whole process, see "requestLimiter".
+//
+// The results channel must be consumed, otherwise the workers stop once it is full.
+func (client *driveClient) newTransferQueue (workers int) *transferQueue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := &transferQueue{
+		client: client,
+		jobs: make(chan transferJob),
+		results: make(chan transferResult, workers),
+		startedAt: time.Now(),
+	}
+
+	for i := 0; i < workers; i++ {
+		queue.workers.Add(1)
+		go queue.work()
+	}
+
+	go func() {
+		queue.workers.Wait()
+		close(queue.results)
+	}()
+
+	return queue
+}
+
+// Sends a job to the workers. It blocks while every worker is busy.
+//
+// Please note that submitting a job after calling "Close" panics.
+func (queue *transferQueue) Submit (job transferJob) {
+	queue.mutex.Lock()
+	queue.stats.Submitted++
+	queue.mutex.Unlock()
+
+	queue.jobs <- job
+}
+
+// Tells the queue that no more jobs will be submitted. The results channel is closed once every job is done.
+func (queue *transferQueue) Close () {
+	close(queue.jobs)
+}
+
+// Returns the channel that receives the result of every job.
+func (queue *transferQueue) Results () <-chan transferResult {
+	return queue.results
+}
+
+// Returns the numbers of the queue so far.
+func (queue *transferQueue) Stats () transferStats {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	stats := queue.stats
+	stats.Elapsed = time.Since(queue.startedAt)
+	return stats
+}
+
+// Runs jobs until the queue is closed.
+func (queue *transferQueue) work () {
+	defer queue.workers.Done()
+
+	for job := range queue.jobs {
+		startedAt := time.Now()
+		file, err := job.run(queue.client)
+
+		queue.mutex.Lock()
+		if err != nil {
+			queue.stats.Failed++
+		} else {
+			queue.stats.Succeeded++
+		}
+		queue.mutex.Unlock()
+
+		queue.results <- transferResult{
+			Job: job,
+			File: file,
+			Err: err,
+			Duration: time.Since(startedAt),
+		}
+	}
+}
+
+// Calls the drive helper of the client that matches the kind of the job.
+func (job transferJob) run (client *driveClient) (*drive.File, error) {
+	switch job.Kind {
+	case copyTransfer:
+		targetId, err := client.getFolderId(job.Target)
+		if err != nil {
+			return nil, err
+		}
+		return client.copyFileTo(job.File, targetId, job.Force)
+	case moveTransfer:
+		return client.moveFileTo(job.Source, job.Target, job.File)
+	case uploadTransfer:
+		file, err := os.Open(job.Source)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return client.uploadFiles(file, job.Target)
+	case downloadTransfer:
+		return job.File, client.downloadFiles(job.File, job.Target, job.Format)
+	}
+
+	return nil, fmt.Errorf("unknown transfer kind %d", job.Kind)
+}
+
+// Prints the aggregate numbers of a transfer queue.
+func (stats transferStats) print () {
+	prettyPrinter(fmt.Sprintf("Transfers finished in %s!\nSubmitted: %d\nSucceeded: %d\nFailed: %d",
+		stats.Elapsed.Round(time.Millisecond), stats.Submitted, stats.Succeeded, stats.Failed))
+}
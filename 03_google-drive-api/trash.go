@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible for the trash ==========
+
+// Returned by "emptyTrash" when the confirmation is denied. Nothing is deleted in this case.
+var errEmptyTrashCancelled = errors.New("emptying the trash was cancelled")
+
+// Fields of the trashed files returned by the functions below.
+const trashFields = "id, name, mimeType, parents, modifiedTime, trashedTime, size"
+
+// This function moves a file to the trash. It can be brought back with "restoreFile" until the trash is emptied.
+func (client *driveClient) trashFile (fileId string) (*drive.File, error) {
+	file, err := client.Service.Files.Update(fileId, &drive.File{Trashed: true}).SupportsAllDrives(true).Do()
+	if err == nil {
+		client.Cache.forget(fileId)
+	}
+	return file, err
+}
+
+// This function brings a file back from the trash to the folder it was in.
+func (client *driveClient) restoreFile (fileId string) (*drive.File, error) {
+	file, err := client.Service.Files.Update(fileId, &drive.File{
+		Trashed: false,
+		ForceSendFields: []string{"Trashed"},
+	}).SupportsAllDrives(true).Fields(cachedFileFields).Do()
+	if err == nil {
+		client.Cache.remember(file)
+	}
+	return file, err
+}
+
+// Selects files inside the trash. Every field is optional, and the empty filter selects the whole trash.
+//
+//  - OlderThan: only files trashed longer ago than that. Drive only tells when a file was trashed for shared drives, for the other files the last modification is used;
+//  - FolderId: only files that were directly inside that folder;
+//  - NamePattern: only files whose name matches the pattern, like "*captura*". The pattern follows "path.Match" and ignores upper and lower case.
+type trashFilter struct {
+	OlderThan   time.Duration
+	FolderId    string
+	NamePattern string
+}
+
+// Checks the parts of the filter that drive cannot search for.
+func (filter trashFilter) matches (file *drive.File) (bool, error) {
+	if filter.NamePattern != "" {
+		matched, err := path.Match(strings.ToLower(filter.NamePattern), strings.ToLower(file.Name))
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	if filter.OlderThan > 0 {
+		trashedAt := file.TrashedTime
+		if trashedAt == "" {
+			trashedAt = file.ModifiedTime
+		}
+
+		trashedTime, err := time.Parse(time.RFC3339, trashedAt)
+		if err != nil {
+			return false, err
+		}
+		if time.Since(trashedTime) < filter.OlderThan {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Returns every file in the trash selected by the filter.
+func (client *driveClient) listTrash (filter trashFilter) ([]*drive.File, error) {
+	query := isTrashed(true)
+	if filter.FolderId != "" {
+		folderId, err := client.getFolderId(filter.FolderId)
+		if err != nil {
+			return nil, err
+		}
+		query = allOf(query, hasParent(folderId))
+	}
+
+	var files []*drive.File
+	iterator := client.searchFiles(context.Background(), query, listOptions{Fields: trashFields})
+	for iterator.Next() {
+		matched, err := filter.matches(iterator.File())
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			files = append(files, iterator.File())
+		}
+	}
+
+	return files, iterator.Err()
+}
+
+// Options of "emptyTrash". When DryRun is true, the files are only returned and nothing is deleted. Confirm, when set, receives the files before they are deleted and must return true for the deletion to happen.
+type emptyTrashOptions struct {
+	Filter  trashFilter
+	DryRun  bool
+	Confirm func(files []*drive.File) bool
+}
+
+// This function permanently deletes the files inside the trash selected by the filter, and returns them.
+//
+// Please note that the files *CANNOT* be retrieved afterwards. Use the dry run option, or a confirmation, to preview what is going to be deleted.
+func (client *driveClient) emptyTrash (options emptyTrashOptions) ([]*drive.File, error) {
+	files, err := client.listTrash(options.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.DryRun || len(files) == 0 {
+		return files, nil
+	}
+
+	if options.Confirm != nil && !options.Confirm(files) {
+		return nil, errEmptyTrashCancelled
+	}
+
+	var deleted []*drive.File
+	for _, file := range files {
+		if err := client.permanentlyDeleteFile(file.Id); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, file)
+	}
+
+	return deleted, nil
+}
+
+// Prints the files that are about to be deleted and asks for a confirmation in the terminal. It can be used as the confirmation of "emptyTrash".
+func confirmInTerminal (files []*drive.File) bool {
+	prettyPrinter(fmt.Sprintf("The following %d files are going to be permanently deleted:", len(files)))
+	for _, file := range files {
+		fmt.Fprintf(messageOutput, "\n\t%s (%s)", file.Name, file.Id)
+	}
+	fmt.Fprint(messageOutput, "\n\nDo you want to continue? [y/N] ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// ========== This section is responsible for resumable uploads of large files ==========
+
+// Endpoint used to start a resumable upload session.
+const resumableUploadUrl = "https://www.googleapis.com/upload/drive/v3/files?uploadType=resumable&supportsAllDrives=true"
+
+// Files bigger than this should be uploaded with "uploadFilesResumable" instead of "uploadFiles".
+const resumableUploadThreshold = 5 * 1024 * 1024
+
+// Drive only accepts chunks whose size is a multiple of 256 KiB, except for the last one.
+const uploadChunkMultiple = 256 * 1024
+
+// Chunk size used when the options do not set one.
+const defaultUploadChunkSize = 8 * 1024 * 1024
+
+// Reports how an upload is going. The rate is measured in bytes per second, only counting the bytes sent since the upload was started or resumed.
+type uploadProgress struct {
+	BytesSent int64
+	Total     int64
+	Rate      float64
+}
+
+// Options of a resumable upload. The chunk size is rounded up to a multiple of 256 KiB, and the progress callback, when set, is called after every chunk.
+type resumableUploadOptions struct {
+	ChunkSize int64
+	Progress  func(uploadProgress)
+}
+
+// Saved next to the source file while an upload is running, so an interrupted upload can continue from where it stopped.
+type resumableUploadState struct {
+	SessionUri   string    `json:"sessionUri"`
+	TargetFolder string    `json:"targetFolder"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"modTime"`
+}
+
+// Returns the path of the state file of a local file.
+func uploadStatePath (filePath string) string {
+	return filePath + ".upload.json"
+}
+
+// Reads the state of an interrupted upload. It only returns a state if it still matches the local file and the target folder, otherwise the return is a nil pointer.
+func loadUploadState (filePath string, fileInfo os.FileInfo, targetFolder string) *resumableUploadState {
+	data, err := os.ReadFile(uploadStatePath(filePath))
+	if err != nil {
+		return nil
+	}
+
+	state := &resumableUploadState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil
+	}
+
+	if state.Size != fileInfo.Size() || !state.ModTime.Equal(fileInfo.ModTime()) || state.TargetFolder != targetFolder {
+		return nil
+	}
+
+	return state
+}
+
+// Saves the state of an upload next to the source file.
+func saveUploadState (filePath string, state *resumableUploadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(uploadStatePath(filePath), data, 0600)
+}
+
+// Starts a new resumable upload session and returns its URI, which is where every chunk must be sent.
+func (client *driveClient) startUploadSession (name string, targetFolder string, size int64) (string, error) {
+	metadata, err := json.Marshal(&drive.File{
+		Name: name,
+		Parents: []string{targetFolder},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, resumableUploadUrl, bytes.NewReader(metadata))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	res, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return "", err
+	}
+
+	sessionUri := res.Header.Get("Location")
+	if sessionUri == "" {
+		return "", fmt.Errorf("drive did not return a resumable upload session")
+	}
+
+	return sessionUri, nil
+}
+
+// Sends a request to an upload session and interprets its response.
+//
+// When the upload is complete, the uploaded file is returned. Otherwise, the return is how many bytes drive already has.
+func (client *driveClient) sendToUploadSession (sessionUri string, body io.Reader, contentLength int64, contentRange string) (*drive.File, int64, error) {
+	req, err := http.NewRequest(http.MethodPut, sessionUri, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.ContentLength = contentLength
+	req.Header.Set("Content-Range", contentRange)
+
+	res, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusPermanentRedirect {
+		return nil, receivedBytes(res.Header.Get("Range")), nil
+	}
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, 0, err
+	}
+
+	uploadedFile := &drive.File{}
+	if err := json.NewDecoder(res.Body).Decode(uploadedFile); err != nil {
+		return nil, 0, err
+	}
+
+	return uploadedFile, 0, nil
+}
+
+// Reads the "Range" header of an upload session, like "bytes=0-1048575", and returns how many bytes were received.
+func receivedBytes (rangeHeader string) int64 {
+	index := strings.LastIndex(rangeHeader, "-")
+	if index == -1 {
+		return 0
+	}
+
+	lastByte, err := strconv.ParseInt(rangeHeader[index+1:], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return lastByte + 1
+}
+
+// Asks drive how many bytes of an interrupted upload it already has.
+//
+// If the session expired, the return is an error. If the upload was already completed, the uploaded file is returned.
+func (client *driveClient) queryUploadSession (sessionUri string, size int64) (*drive.File, int64, error) {
+	return client.sendToUploadSession(sessionUri, nil, 0, fmt.Sprintf("bytes */%d", size))
+}
+
+// This function uploads a local file to a given drive parent in chunks, so a dropped connection only sends the current chunk again. You have to provide the local file as an "os.File" pointer, the destination drive folder and the upload options.
+//
+// While the upload runs, a small state file is saved next to the source file. If the upload is interrupted, calling this function again with the same file and folder continues the upload from where it stopped. The state file is removed when the upload completes.
+//
+// For small files, "uploadFiles" is simpler and faster. Please note that this function *DOES NOT* checks for duplicates either.
+func (client *driveClient) uploadFilesResumable (file *os.File, targetDriveFolder string, options resumableUploadOptions) (*drive.File, error) {
+	targetDriveFolder, err := client.getFolderId(targetDriveFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fileInfo.Size()
+	if size == 0 {
+		return client.uploadFiles(file, targetDriveFolder)
+	}
+
+	chunkSize := options.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultUploadChunkSize
+	}
+	if remainder := chunkSize % uploadChunkMultiple; remainder != 0 {
+		chunkSize += uploadChunkMultiple - remainder
+	}
+
+	var offset int64
+	state := loadUploadState(file.Name(), fileInfo, targetDriveFolder)
+	if state != nil {
+		uploadedFile, received, err := client.queryUploadSession(state.SessionUri, size)
+		if uploadedFile != nil {
+			os.Remove(uploadStatePath(file.Name()))
+			return uploadedFile, nil
+		}
+		if err != nil {
+			state = nil
+		}
+		offset = received
+	}
+
+	if state == nil {
+		offset = 0
+		sessionUri, err := client.startUploadSession(fileInfo.Name(), targetDriveFolder, size)
+		if err != nil {
+			return nil, err
+		}
+
+		state = &resumableUploadState{
+			SessionUri: sessionUri,
+			TargetFolder: targetDriveFolder,
+			Size: size,
+			ModTime: fileInfo.ModTime(),
+		}
+		if err := saveUploadState(file.Name(), state); err != nil {
+			return nil, err
+		}
+	}
+
+	startedAt := time.Now()
+	startOffset := offset
+
+	for {
+		end := offset + chunkSize
+		if end > size {
+			end = size
+		}
+
+		chunk := io.NewSectionReader(file, offset, end-offset)
+		contentRange := fmt.Sprintf("bytes %d-%d/%d", offset, end-1, size)
+		uploadedFile, received, err := client.sendToUploadSession(state.SessionUri, chunk, end-offset, contentRange)
+		if err != nil {
+			return nil, err
+		}
+
+		if uploadedFile != nil {
+			received = size
+		}
+
+		if options.Progress != nil {
+			rate := 0.0
+			if elapsed := time.Since(startedAt).Seconds(); elapsed > 0 {
+				rate = float64(received-startOffset) / elapsed
+			}
+			options.Progress(uploadProgress{BytesSent: received, Total: size, Rate: rate})
+		}
+
+		if uploadedFile != nil {
+			os.Remove(uploadStatePath(file.Name()))
+			return uploadedFile, nil
+		}
+
+		offset = received
+	}
+}
+
+// Prints the progress of an upload. It can be used as the progress callback of "uploadFilesResumable".
+func progressPrinter (progress uploadProgress) {
+	fmt.Fprintf(messageOutput, "\n\tUploaded %d of %d bytes (%.1f%%) at %.1f KB/s",
+		progress.BytesSent, progress.Total, float64(progress.BytesSent)*100/float64(progress.Total), progress.Rate/1024)
+}
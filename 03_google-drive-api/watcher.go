@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// ========== This section is responsible to watch a folder for changes ==========
+
+// What happened to a file inside the watched folder.
+type fileEventKind int
+
+const (
+	createdEvent fileEventKind = iota
+	modifiedEvent
+	movedEvent
+	trashedEvent
+	deletedEvent
+)
+
+func (kind fileEventKind) String () string {
+	switch kind {
+	case createdEvent:
+		return "created"
+	case modifiedEvent:
+		return "modified"
+	case movedEvent:
+		return "moved"
+	case trashedEvent:
+		return "trashed"
+	case deletedEvent:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// A change on a file inside the watched folder. File holds the new metadata of the file, except for deleted files, when it is a nil pointer.
+//
+// Moved events are sent both when a file moves inside the folder and when it leaves the folder.
+type fileEvent struct {
+	Kind   fileEventKind
+	FileId string
+	Name   string
+	File   *drive.File
+	Time   time.Time
+}
+
+// What the watcher remembers about each file inside the watched folder, so it can tell created, moved and trashed files apart.
+type watchedFile struct {
+	Name    string   `json:"name"`
+	Parents []string `json:"parents"`
+	Folder  bool     `json:"folder,omitempty"`
+	Trashed bool     `json:"trashed,omitempty"`
+}
+
+// Everything the watcher saves to disk. The page token is the position in the changes feed, so a restarted watcher continues exactly where it stopped.
+type watcherState struct {
+	FolderId  string                 `json:"folderId"`
+	PageToken string                 `json:"pageToken"`
+	Files     map[string]watchedFile `json:"files"`
+}
+
+// Watches a drive folder and every subfolder inside of it, turning the changes feed into file events.
+type changesWatcher struct {
+	client    *driveClient
+	statePath string
+	state     watcherState
+}
+
+// Fields requested from the changes feed.
+const changeFields = "nextPageToken, newStartPageToken, changes(fileId, removed, time, file(id, name, mimeType, parents, trashed, modifiedTime))"
+
+// Creates a watcher for a folder. You have to provide the folder URL or ID and the path of the file where the watcher saves its state.
+//
+// If the state file already exists, the watcher continues from the saved position. Otherwise, the current position of the changes feed is saved and the folder is read once, so only the changes made from now on become events.
+func (client *driveClient) newChangesWatcher (folderUrl string, statePath string) (*changesWatcher, error) {
+	folderId, err := client.getFolderId(folderUrl)
+	if err != nil {
+		return nil, err
+	}
+	watcher := &changesWatcher{client: client, statePath: statePath}
+
+	data, err := os.ReadFile(statePath)
+	if err == nil {
+		if err := json.Unmarshal(data, &watcher.state); err != nil {
+			return nil, err
+		}
+		if watcher.state.FolderId == folderId {
+			return watcher, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// The token is taken before reading the folder, so nothing that changes while the folder is read is missed.
+	startToken, err := client.Service.Changes.GetStartPageToken().SupportsAllDrives(true).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	watcher.state = watcherState{
+		FolderId: folderId,
+		PageToken: startToken.StartPageToken,
+		Files: map[string]watchedFile{
+			folderId: {Folder: true},
+		},
+	}
+	if err := watcher.readFolder(folderId); err != nil {
+		return nil, err
+	}
+
+	return watcher, watcher.save()
+}
+
+// Remembers every file inside a folder and its subfolders.
+func (watcher *changesWatcher) readFolder (folderId string) error {
+	iterator := watcher.client.listFolder(context.Background(), folderId, listOptions{Fields: "id, name, mimeType, parents"})
+	for iterator.Next() {
+		file := iterator.File()
+		watcher.remember(file)
+
+		if file.MimeType == "application/vnd.google-apps.folder" {
+			if err := watcher.readFolder(file.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return iterator.Err()
+}
+
+// Saves the state of the watcher. The state is written to a temporary file first, so a crash never leaves a broken state behind.
+func (watcher *changesWatcher) save () error {
+	data, err := json.Marshal(watcher.state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(watcher.statePath), 0755); err != nil {
+		return err
+	}
+
+	temporaryPath := watcher.statePath + ".tmp"
+	if err := os.WriteFile(temporaryPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, watcher.statePath)
+}
+
+// Stores the metadata of a file inside the watched folder.
+func (watcher *changesWatcher) remember (file *drive.File) {
+	watcher.state.Files[file.Id] = watchedFile{
+		Name: file.Name,
+		Parents: file.Parents,
+		Folder: file.MimeType == "application/vnd.google-apps.folder",
+		Trashed: file.Trashed,
+	}
+}
+
+// Checks if any of the parents is the watched folder or one of its subfolders.
+func (watcher *changesWatcher) isInside (parents []string) bool {
+	for _, parent := range parents {
+		if known, ok := watcher.state.Files[parent]; ok && known.Folder {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Turns a single change into an event, updating what the watcher knows about the file. The return is false when the change has nothing to do with the watched folder.
+func (watcher *changesWatcher) apply (change *drive.Change) (fileEvent, bool) {
+	changeTime, _ := time.Parse(time.RFC3339, change.Time)
+	known, wasInside := watcher.state.Files[change.FileId]
+	event := fileEvent{FileId: change.FileId, Name: known.Name, Time: changeTime}
+
+	if change.FileId == watcher.state.FolderId {
+		return event, false
+	}
+
+	if change.Removed || change.File == nil {
+		if !wasInside {
+			return event, false
+		}
+		delete(watcher.state.Files, change.FileId)
+		event.Kind = deletedEvent
+		return event, true
+	}
+
+	file := change.File
+	event.File = file
+	event.Name = file.Name
+
+	if !watcher.isInside(file.Parents) {
+		if !wasInside {
+			return event, false
+		}
+		delete(watcher.state.Files, file.Id)
+		event.Kind = movedEvent
+		return event, true
+	}
+
+	watcher.remember(file)
+
+	switch {
+	case !wasInside:
+		if file.Trashed {
+			return event, false
+		}
+		event.Kind = createdEvent
+	case file.Trashed && !known.Trashed:
+		event.Kind = trashedEvent
+	case !sameParents(known.Parents, file.Parents):
+		event.Kind = movedEvent
+	default:
+		event.Kind = modifiedEvent
+	}
+
+	return event, true
+}
+
+// Checks if two lists of parents have the same IDs.
+func sameParents (a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	ids := make(map[string]bool)
+	for _, id := range a {
+		ids[id] = true
+	}
+	for _, id := range b {
+		if !ids[id] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reads every change made since the last poll and calls the handler with each event, in the order they happened.
+//
+// The position in the changes feed is saved after every page, once its events were handled, so a restarted watcher neither misses nor repeats events. If the handler panics, the process dies or the context is cancelled in the middle of a page, the position is not saved and that page is read again by the next watcher opened on the same state.
+func (watcher *changesWatcher) Poll (ctx context.Context, handler func(fileEvent)) error {
+	for {
+		changeList, err := watcher.client.Service.Changes.List(watcher.state.PageToken).
+			Fields(changeFields).
+			IncludeItemsFromAllDrives(true).
+			SupportsAllDrives(true).
+			IncludeRemoved(true).
+			Context(ctx).
+			Do()
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changeList.Changes {
+			if event, ok := watcher.apply(change); ok {
+				handler(event)
+			}
+		}
+
+		// The handler may have dropped events once the context was cancelled, like the one of "Events" does, so the page was not fully delivered.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if changeList.NextPageToken != "" {
+			watcher.state.PageToken = changeList.NextPageToken
+		} else {
+			watcher.state.PageToken = changeList.NewStartPageToken
+		}
+		if err := watcher.save(); err != nil {
+			return err
+		}
+
+		if changeList.NextPageToken == "" {
+			return nil
+		}
+	}
+}
+
+// Polls the changes feed every interval until the context is cancelled or an error occurs. The return is the error that stopped the watcher, which is the context error when it was cancelled.
+func (watcher *changesWatcher) Watch (ctx context.Context, interval time.Duration, handler func(fileEvent)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := watcher.Poll(ctx, handler); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Works like "Watch", but sends the events to a channel. Both channels are closed when the watcher stops, and the error channel receives the error that stopped it.
+func (watcher *changesWatcher) Events (ctx context.Context, interval time.Duration) (<-chan fileEvent, <-chan error) {
+	events := make(chan fileEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		errs <- watcher.Watch(ctx, interval, func(event fileEvent) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return events, errs
+}
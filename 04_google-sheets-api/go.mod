@@ -3,9 +3,11 @@ module main.go
 go 1.17
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/joho/godotenv v1.4.0
 	golang.org/x/oauth2 v0.0.0-20220223155221-ee480838109b
 	google.golang.org/api v0.70.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
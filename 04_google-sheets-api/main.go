@@ -2,118 +2,50 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 
-	"github.com/joho/godotenv"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 )
 
 // ================================= Client Authentication =================================
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config) *http.Client {
-	// The file token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tokFile := "./credentials/token.json"
-	tok, err := tokenFromFile(tokFile)
-	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
-	}
-	return config.Client(context.Background(), tok)
-}
-
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-			"authorization code: \n%v\n", authURL)
-
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code: %v", err)
-	}
-
-	tok, err := config.Exchange(context.TODO(), authCode)
-	if err != nil {
-		log.Fatalf("Unable to retrieve token from web: %v", err)
-	}
-	return tok
-}
-
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
+// Returns the client that sends every request with the tokens of the given source.
+// 
+// Temporary errors are retried by the client following "apiRetryPolicy".
+func getClient(tokenSource oauth2.TokenSource) *http.Client {
+	client := oauth2.NewClient(context.Background(), tokenSource)
+	client.Transport = &retryTransport{
+		base: client.Transport,
+		policy: apiRetryPolicy,
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
+	return client
 }
 
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
-	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
-}
-
-// Gets the service used to make every drive operation
-func getService () *sheets.Service {
-	ctx := context.Background()
-	b, err := ioutil.ReadFile("./credentials/creds.json")
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
-	}
-
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, "https://www.googleapis.com/auth/spreadsheets")
-	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
-	}
-	client := getClient(config)
 
-	srv, err := sheets.NewService(ctx, option.WithHTTPClient(client))
+// Gets the default client, the one used by "main". The account is chosen by "activeProfile", see "newSheetsClient".
+func getDefaultClient () *sheetsClient {
+	client, err := newSheetsClient(activeProfile)
 	if err != nil {
 		log.Fatalf("Unable to retrieve Sheets client: %v", err)
 	}
 
-	return srv
+	return client
 }
 
 
 // =============================== General Purpose Functions ===============================
 
-// Retrieves environment variables from the ".env" file stored in this repo.
-func getGoDotEnvVariable(key string) string {
-
-	// load .env file
-	err := godotenv.Load(".env")
-  
-	if err != nil {
-	  log.Fatalf("Error loading .env file")
+// Retrieves the ID from a spreadsheet URL. An empty URL means the default spreadsheet of the profile in use.
+func (client *sheetsClient) getSpreadsheetId (url string) string {
+	if url == "" {
+		url = client.DefaultSpreadsheet
 	}
-  
-	return os.Getenv(key)
-}
-
-func getSpreadsheetId (url string) string {
 	if strings.HasPrefix(url, "https") {
 		arr := strings.Split(url, "/")
         return arr[5]
@@ -138,9 +70,9 @@ func prettyPrinter (msg string) {
 	-----------------`, msg)
 }
 
-func checkSheetDuplicates (spreadsheetUrl string, sheetName string) (bool, error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
-	spreadsheet, err := getSpreadsheetInfo(spreadsheetId)
+func (client *sheetsClient) checkSheetDuplicates (spreadsheetUrl string, sheetName string) (bool, error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
+	spreadsheet, err := client.getSpreadsheetInfo(spreadsheetId)
 	if err != nil {
 		return true, err
 	}
@@ -157,24 +89,24 @@ func checkSheetDuplicates (spreadsheetUrl string, sheetName string) (bool, error
 
 // ===================================== Reading sheets =====================================
 
-func getDataFromSpreadsheet (spreadsheetUrl string, readRange string) (readedRange *sheets.ValueRange, err error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) getDataFromSpreadsheet (spreadsheetUrl string, readRange string) (readedRange *sheets.ValueRange, err error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
-	readedRange, err = srv.Spreadsheets.Values.Get(spreadsheetId, readRange).Do()
+	readedRange, err = client.Service.Spreadsheets.Values.Get(spreadsheetId, readRange).Do()
 	return readedRange, err
 }
 
-func getMultipleDataFromSpreadsheet (spreadsheetUrl string, readRange ...string) (readedRange *sheets.BatchGetValuesResponse, err error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) getMultipleDataFromSpreadsheet (spreadsheetUrl string, readRange ...string) (readedRange *sheets.BatchGetValuesResponse, err error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
-	readedRange, err = srv.Spreadsheets.Values.BatchGet(spreadsheetId).Ranges(readRange...).Do()
+	readedRange, err = client.Service.Spreadsheets.Values.BatchGet(spreadsheetId).Ranges(readRange...).Do()
 	return readedRange, err
 }
 
-func getSpreadsheetInfo (spreadsheetUrl string) (*sheets.Spreadsheet, error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) getSpreadsheetInfo (spreadsheetUrl string) (*sheets.Spreadsheet, error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
-	sheet, err := srv.Spreadsheets.Get(spreadsheetId).Do()
+	sheet, err := client.Service.Spreadsheets.Get(spreadsheetId).Do()
 
 	return sheet, err
 }
@@ -182,7 +114,7 @@ func getSpreadsheetInfo (spreadsheetUrl string) (*sheets.Spreadsheet, error) {
 
 // ============================== Creating Spreadsheet & Tabs ==============================
 
-func createSpreadsheet(spreadsheetTitle string, tabs ...string) (*sheets.Spreadsheet, error) {
+func (client *sheetsClient) createSpreadsheet(spreadsheetTitle string, tabs ...string) (*sheets.Spreadsheet, error) {
 	var spreadsheetTabs []*sheets.Sheet
 	for _, tabName := range(tabs) {
 		spreadsheetTabs = append(spreadsheetTabs, &sheets.Sheet{
@@ -192,7 +124,7 @@ func createSpreadsheet(spreadsheetTitle string, tabs ...string) (*sheets.Spreads
 		})
 	}
 
-	sheet, err := srv.Spreadsheets.Create(&sheets.Spreadsheet{
+	sheet, err := client.Service.Spreadsheets.Create(&sheets.Spreadsheet{
 		Properties: &sheets.SpreadsheetProperties{
 			Title: spreadsheetTitle,
 		},
@@ -202,12 +134,12 @@ func createSpreadsheet(spreadsheetTitle string, tabs ...string) (*sheets.Spreads
 	return sheet, err
 }
 
-func createNewSheet (spreadsheetUrl string, tabNames ...string) (*sheets.BatchUpdateSpreadsheetResponse, error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) createNewSheet (spreadsheetUrl string, tabNames ...string) (*sheets.BatchUpdateSpreadsheetResponse, error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
 	var requests []*sheets.Request
 	for _, tabName := range(tabNames) {
-		isDuplicate, err := checkSheetDuplicates(spreadsheetUrl, tabName)
+		isDuplicate, err := client.checkSheetDuplicates(spreadsheetUrl, tabName)
 		if err != nil {
 			return nil, err
 		}
@@ -225,7 +157,7 @@ func createNewSheet (spreadsheetUrl string, tabNames ...string) (*sheets.BatchUp
 		}
 	}
 	
-	update, err := srv.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
+	update, err := client.Service.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
 		Requests: requests,
 		IncludeSpreadsheetInResponse: true,
 	}).Do()
@@ -235,11 +167,11 @@ func createNewSheet (spreadsheetUrl string, tabNames ...string) (*sheets.BatchUp
 
 // ================================= Updating Spreadsheets =================================
 
-func duplicateSheet (spreadsheetUrl string, sourceSheetId int64, newSheetIndex int64, newSheetName string) (*sheets.BatchUpdateSpreadsheetResponse, error){
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) duplicateSheet (spreadsheetUrl string, sourceSheetId int64, newSheetIndex int64, newSheetName string) (*sheets.BatchUpdateSpreadsheetResponse, error){
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
 	var requests []*sheets.Request
-	isDuplicate, err := checkSheetDuplicates(spreadsheetUrl, newSheetName)
+	isDuplicate, err := client.checkSheetDuplicates(spreadsheetUrl, newSheetName)
 	if err != nil {
 		return nil, err
 	}
@@ -256,7 +188,7 @@ func duplicateSheet (spreadsheetUrl string, sourceSheetId int64, newSheetIndex i
 		return nil, fmt.Errorf("erro ao duplicar a aba com id %d\n Já existe uma aba com o nome %q", sourceSheetId, newSheetName)
 	}
 
-	update, err := srv.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
+	update, err := client.Service.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
 		Requests: requests,
 		IncludeSpreadsheetInResponse: true,
 	}).Do()
@@ -264,8 +196,8 @@ func duplicateSheet (spreadsheetUrl string, sourceSheetId int64, newSheetIndex i
 	return update, err
 }
 
-func deleteSheet (spreadsheetUrl string, sheetId int64) (*sheets.BatchUpdateSpreadsheetResponse, error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) deleteSheet (spreadsheetUrl string, sheetId int64) (*sheets.BatchUpdateSpreadsheetResponse, error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
 	var requests []*sheets.Request
 	requests = append(requests, &sheets.Request{
@@ -274,7 +206,7 @@ func deleteSheet (spreadsheetUrl string, sheetId int64) (*sheets.BatchUpdateSpre
 		},
 	})
 
-	update, err := srv.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
+	update, err := client.Service.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
 		Requests: requests,
 		IncludeSpreadsheetInResponse: true,
 	}).Do()
@@ -282,10 +214,10 @@ func deleteSheet (spreadsheetUrl string, sheetId int64) (*sheets.BatchUpdateSpre
 	return update, err
 }
 
-func updateSpreadsheet (requestedChanges []*sheets.Request, spreadsheetUrl string) (*sheets.BatchUpdateSpreadsheetResponse, error) {
-    spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) updateSpreadsheet (requestedChanges []*sheets.Request, spreadsheetUrl string) (*sheets.BatchUpdateSpreadsheetResponse, error) {
+    spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
-	update, err := srv.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
+	update, err := client.Service.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
 		Requests: requestedChanges,
 		IncludeSpreadsheetInResponse: true,
 	}).Do()
@@ -296,20 +228,20 @@ func updateSpreadsheet (requestedChanges []*sheets.Request, spreadsheetUrl strin
 
 // =================================== Writing in a sheet ===================================
 
-func writeSingleRange (spreadsheetUrl string, newLines [][]interface{}, writeRange string) (*sheets.UpdateValuesResponse, error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) writeSingleRange (spreadsheetUrl string, newLines [][]interface{}, writeRange string) (*sheets.UpdateValuesResponse, error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
-	writedRange, err := srv.Spreadsheets.Values.Update(spreadsheetId, writeRange, &sheets.ValueRange{
+	writedRange, err := client.Service.Spreadsheets.Values.Update(spreadsheetId, writeRange, &sheets.ValueRange{
 		Values: newLines,
 	}).ValueInputOption("USER_ENTERED").IncludeValuesInResponse(true).Do()
 
 	return writedRange, err
 }
 
-func writeMultipleRanges (spreadsheetUrl string, data []*sheets.ValueRange) (*sheets.BatchUpdateValuesResponse, error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) writeMultipleRanges (spreadsheetUrl string, data []*sheets.ValueRange) (*sheets.BatchUpdateValuesResponse, error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
-	writedRanges, err := srv.Spreadsheets.Values.BatchUpdate(spreadsheetId, &sheets.BatchUpdateValuesRequest{
+	writedRanges, err := client.Service.Spreadsheets.Values.BatchUpdate(spreadsheetId, &sheets.BatchUpdateValuesRequest{
 		ValueInputOption: "USER_ENTERED",
 		Data: data,
 		IncludeValuesInResponse: true,
@@ -318,10 +250,10 @@ func writeMultipleRanges (spreadsheetUrl string, data []*sheets.ValueRange) (*sh
 	return writedRanges, err
 }
 
-func appendNewRows (spreadsheetUrl string, table [][]interface{}, writeRange string) (*sheets.AppendValuesResponse, error) {
-	spreadsheetId := getSpreadsheetId(spreadsheetUrl)
+func (client *sheetsClient) appendNewRows (spreadsheetUrl string, table [][]interface{}, writeRange string) (*sheets.AppendValuesResponse, error) {
+	spreadsheetId := client.getSpreadsheetId(spreadsheetUrl)
 
-	appendedValues, err := srv.Spreadsheets.Values.Append(spreadsheetId, writeRange, &sheets.ValueRange{
+	appendedValues, err := client.Service.Spreadsheets.Values.Append(spreadsheetId, writeRange, &sheets.ValueRange{
 		Values: table,
 	}).ValueInputOption("USER_ENTERED").IncludeValuesInResponse(true).Do()
 
@@ -329,17 +261,43 @@ func appendNewRows (spreadsheetUrl string, table [][]interface{}, writeRange str
 }
 
 
-// ================================ Sheets service variable ================================
+// ================================= Sheets default client =================================
 
-var srv *sheets.Service = getService()
+// The client used by "main", created after the configuration is loaded. Other accounts can be used at the same time through their own clients, see "newSheetsClient".
+var defaultClient *sheetsClient
 
 
 // ============================= Testing the created functions =============================
 
 func main() {
-	spreadsheetUrl := getGoDotEnvVariable("GOOGLE_SAMPLE_SPREADSHEET_URL")
-	readRange := getGoDotEnvVariable("GOOGLE_SAMPLE_SPREADSHEET_RANGE")
-	data, err := getDataFromSpreadsheet(spreadsheetUrl, readRange)
+	configPath := flag.String("config", "", "path of the YAML or TOML config file, see README")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and where each value comes from, then exit")
+	flag.StringVar(&activeProfile, "profile", "", "account profile to use, from "+profilesPath)
+	flag.Parse()
+
+	loaded, err := loadConfig(configKeys, flag.CommandLine, *configPath)
+	settings = loaded
+
+	// The effective configuration is printed even when it is invalid, it shows where the invalid values came from.
+	if *printConfig {
+		settings.print(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *printConfig {
+		return
+	}
+
+	if err := settings.require("sample_spreadsheet_url", "sample_spreadsheet_range", "my_spreadsheet"); err != nil {
+		log.Fatal(err)
+	}
+
+	defaultClient = getDefaultClient()
+
+	spreadsheetUrl := settings.String("sample_spreadsheet_url")
+	readRange := settings.String("sample_spreadsheet_range")
+	data, err := defaultClient.getDataFromSpreadsheet(spreadsheetUrl, readRange)
 	errorPrinter(err)
 
 	if len(data.Values) == 0 {
@@ -353,7 +311,7 @@ func main() {
 	}
 
 
-	multipleData, err := getMultipleDataFromSpreadsheet(spreadsheetUrl, readRange)
+	multipleData, err := defaultClient.getMultipleDataFromSpreadsheet(spreadsheetUrl, readRange)
 	errorPrinter(err)
 	for rangeNum, vr := range multipleData.ValueRanges {
 		fmt.Printf("\nRange: %d", rangeNum)
@@ -362,32 +320,32 @@ func main() {
 		}
 	}
 
-	test, err := createSpreadsheet("Será que deu", "Aba 1", "Pedro", "Dev")
+	test, err := defaultClient.createSpreadsheet("Será que deu", "Aba 1", "Pedro", "Dev")
 	errorPrinter(err)
 	prettyPrinter(fmt.Sprintf("Nova aba: %s", test.SpreadsheetUrl))
 
-	mySpreadsheetUrl := getGoDotEnvVariable("MY_SPREADSHEET")
+	mySpreadsheetUrl := settings.String("my_spreadsheet")
 
-	newSheet, err := createNewSheet(mySpreadsheetUrl, "Mano", "Muito", "brabíssimo")
+	newSheet, err := defaultClient.createNewSheet(mySpreadsheetUrl, "Mano", "Muito", "brabíssimo")
 	if err == nil {
 		prettyPrinter("Last tab created: " + newSheet.UpdatedSpreadsheet.Sheets[len(newSheet.UpdatedSpreadsheet.Sheets) - 1].Properties.Title)
 	}
 	
-	sheet, err := getSpreadsheetInfo(mySpreadsheetUrl)
+	sheet, err := defaultClient.getSpreadsheetInfo(mySpreadsheetUrl)
 	errorPrinter(err)
 	for _, sheetName := range(sheet.Sheets) {
 		if sheetName.Properties.Title == "brabíssimo" {
-			_, err := duplicateSheet(mySpreadsheetUrl, sheetName.Properties.SheetId, sheetName.Properties.Index + 1, "brabíssimo 2.0")
+			_, err := defaultClient.duplicateSheet(mySpreadsheetUrl, sheetName.Properties.SheetId, sheetName.Properties.Index + 1, "brabíssimo 2.0")
 			errorPrinter(err)
 		}
 	}
 
-	sheet, err = getSpreadsheetInfo(mySpreadsheetUrl)
+	sheet, err = defaultClient.getSpreadsheetInfo(mySpreadsheetUrl)
 	errorPrinter(err)
 	var changes []*sheets.Request
 	for _, sheetName := range(sheet.Sheets) {
 		prettyPrinter(fmt.Sprintf("Deletando: %#v", sheetName.Properties.Title))
-		_, err := deleteSheet(mySpreadsheetUrl, sheetName.Properties.SheetId)
+		_, err := defaultClient.deleteSheet(mySpreadsheetUrl, sheetName.Properties.SheetId)
 		if err != nil {
 			changes = append(changes, &sheets.Request{
 				DuplicateSheet: &sheets.DuplicateSheetRequest{
@@ -399,24 +357,24 @@ func main() {
 		}
 	}
 
-	updatedSheet, err := updateSpreadsheet(changes, mySpreadsheetUrl)
+	updatedSheet, err := defaultClient.updateSpreadsheet(changes, mySpreadsheetUrl)
 	errorPrinter(err)
 	if err == nil {
 		var multipleWriteData []*sheets.ValueRange
 		for _, sheetName := range(updatedSheet.UpdatedSpreadsheet.Sheets) {
 			prettyPrinter(fmt.Sprintf("%#v", sheetName.Properties.Title))
 	
-			_, err := writeSingleRange(mySpreadsheetUrl, data.Values, sheetName.Properties.Title+"!A1")
+			_, err := defaultClient.writeSingleRange(mySpreadsheetUrl, data.Values, sheetName.Properties.Title+"!A1")
 			errorPrinter(err)
 	
 			multipleWriteData = append(multipleWriteData, &sheets.ValueRange{
 				Range: sheetName.Properties.Title + "!H2",
 				Values: data.Values,
 			})
-			_, err = writeMultipleRanges(mySpreadsheetUrl, multipleWriteData)
+			_, err = defaultClient.writeMultipleRanges(mySpreadsheetUrl, multipleWriteData)
 			errorPrinter(err)
 
-			_, err = appendNewRows(mySpreadsheetUrl, data.Values, sheetName.Properties.Title + "!F10")
+			_, err = defaultClient.appendNewRows(mySpreadsheetUrl, data.Values, sheetName.Properties.Title + "!F10")
 			errorPrinter(err)
 		}	
 	}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ================================== Browser Sign In ==================================
+
+// How long the sign in waits for the browser before giving up.
+const signInTimeout = 5 * time.Minute
+
+// Returned when the user denies the access, or google answers the sign in with an error.
+var errSignInDenied = errors.New("the sign in was denied")
+
+// Returns a random value encoded for URLs, used as the state and as the PKCE verifier.
+func randomUrlValue () (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// Returns the PKCE challenge of a verifier, following the "S256" method.
+func pkceChallenge (verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// Tries to open a URL in the default browser. The return is false when there is no browser to open.
+func openBrowser (link string) bool {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		command = exec.Command("open", link)
+	case "windows":
+		command = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return false
+		}
+		command = exec.Command("xdg-open", link)
+	}
+
+	return command.Start() == nil
+}
+
+// Reads the code of a sign in answer, checking that its state is the one that was sent.
+func readSignInAnswer (query url.Values, state string) (string, error) {
+	if answerError := query.Get("error"); answerError != "" {
+		return "", fmt.Errorf("%w: %s", errSignInDenied, answerError)
+	}
+	if query.Get("state") != state {
+		return "", fmt.Errorf("the sign in answer has an invalid state, start the sign in again")
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("the sign in answer has no authorization code")
+	}
+
+	return code, nil
+}
+
+// This function signs a user in through the browser and returns the token. You have to provide the OAuth client config, and whether there is no browser on this machine.
+//
+// A temporary server is started on a random local port to receive the answer of google, and the sign in link is opened in the browser. The answer must carry the random state that was sent, and the code is exchanged with a PKCE verifier, so a code stolen on the way cannot be used.
+//
+// When "headless" is true, or when the local server cannot be started, the link is only printed. The sign in can then be done on any other device, and the address the browser ends at, or just its code, must be pasted back in the terminal.
+func getTokenFromWeb (config *oauth2.Config, headless bool) (*oauth2.Token, error) {
+	state, err := randomUrlValue()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomUrlValue()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		headless = true
+	}
+
+	signInConfig := *config
+	if listener != nil {
+		defer listener.Close()
+		signInConfig.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr())
+	} else {
+		signInConfig.RedirectURL = "http://127.0.0.1/"
+	}
+
+	authURL := signInConfig.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("code_challenge", pkceChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	var code string
+	if headless {
+		code, err = readCodeFromTerminal(authURL, state)
+	} else {
+		code, err = receiveCodeOnLoopback(listener, authURL, state)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tok, err := signInConfig.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from web: %w", err)
+	}
+
+	return tok, nil
+}
+
+// Opens the sign in link and waits for the answer of google on the local server.
+func receiveCodeOnLoopback (listener net.Listener, authURL string, state string) (string, error) {
+	type answer struct {
+		code string
+		err  error
+	}
+	answers := make(chan answer, 1)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Browsers also ask for the icon of the page, which is not the answer.
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+
+		// Only the answer to this sign in ends it. Any other request, like a stray visit or a forged one, is refused and the sign in keeps waiting.
+		query := req.URL.Query()
+		if query.Get("error") == "" && query.Get("state") != state {
+			http.Error(w, "this is not the answer to the current sign in", http.StatusBadRequest)
+			return
+		}
+
+		code, err := readSignInAnswer(query, state)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprint(w, "The sign in is complete, you can close this tab and go back to the terminal.")
+		}
+
+		select {
+		case answers <- answer{code: code, err: err}:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if !openBrowser(authURL) {
+		fmt.Fprintf(os.Stderr, "Go to the following link in your browser to sign in:\n%v\n", authURL)
+	} else {
+		fmt.Fprintf(os.Stderr, "Your browser was opened to sign in. If it was not, go to the following link:\n%v\n", authURL)
+	}
+
+	select {
+	case received := <-answers:
+		return received.code, received.err
+	case <-time.After(signInTimeout):
+		return "", fmt.Errorf("the sign in was not completed within %s", signInTimeout)
+	}
+}
+
+// Prints the sign in link and reads the answer pasted in the terminal, for machines without a browser.
+//
+// When the whole address is pasted, its state is checked as in the loopback sign in. A bare code carries no state, so it is accepted as it is, and it is still protected by the PKCE verifier, which only this program knows.
+func readCodeFromTerminal (authURL string, state string) (string, error) {
+	fmt.Fprintf(os.Stderr, "Go to the following link in a browser, on any device, to sign in:\n%v\n\n", authURL)
+	fmt.Fprint(os.Stderr, "The browser ends at an address that does not load. Paste that whole address here, so its state can be checked, or just the value of its \"code\" parameter: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("unable to read authorization code: %w", err)
+	}
+	line = strings.TrimSpace(line)
+
+	if !strings.Contains(line, "code=") {
+		if line == "" {
+			return "", fmt.Errorf("no authorization code was given")
+		}
+		return line, nil
+	}
+
+	pasted, err := url.Parse(line)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the pasted address: %w", err)
+	}
+
+	return readSignInAnswer(pasted.Query(), state)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ========== This section is responsible to retry failed requests ==========
+
+// Controls how failed requests are retried.
+//
+// The delay between attempts grows exponentially from BaseDelay up to MaxDelay, with a random jitter, unless the response tells how long to wait with a "Retry-After" header. No attempt starts after the Deadline, counted from the first attempt.
+//
+// Non-idempotent requests (POST and PATCH) are only retried when RetryNonIdempotent is true, or when their context was created with "withNonIdempotentRetry".
+type retryPolicy struct {
+	MaxAttempts        int
+	Deadline           time.Duration
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	RetryNonIdempotent bool
+}
+
+// Policy used by the client created in "getClient".
+var apiRetryPolicy = retryPolicy{
+	MaxAttempts: 5,
+	Deadline: 2 * time.Minute,
+	BaseDelay: 1 * time.Second,
+	MaxDelay: 32 * time.Second,
+}
+
+type nonIdempotentRetryKey struct{}
+
+// Returns a context that allows a POST or PATCH request to be retried. Use it with the "Context" method of a sheets call when running it twice cannot cause harm.
+func withNonIdempotentRetry (ctx context.Context) context.Context {
+	return context.WithValue(ctx, nonIdempotentRetryKey{}, true)
+}
+
+// HTTP transport that sends a request again when the API answers with a temporary error.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy retryPolicy
+}
+
+// Random source used for the jitter, since "math/rand" is not safe to seed per call.
+var (
+	jitterMutex  sync.Mutex
+	jitterSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func (transport *retryTransport) RoundTrip (req *http.Request) (*http.Response, error) {
+	if !transport.canRetry(req) {
+		return transport.base.RoundTrip(req)
+	}
+
+	deadline := time.Now().Add(transport.policy.Deadline)
+	attempt := 0
+
+	for {
+		attempt++
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		res, err := transport.base.RoundTrip(req)
+		if attempt >= transport.policy.MaxAttempts || !shouldRetry(res, err) {
+			return res, err
+		}
+
+		delay := transport.backoff(attempt, res)
+		if transport.policy.Deadline > 0 && time.Now().Add(delay).After(deadline) {
+			return res, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// Checks if a request can be sent more than once. Requests with a body that cannot be read again are never retried.
+func (transport *retryTransport) canRetry (req *http.Request) bool {
+	if transport.policy.MaxAttempts <= 1 {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	optedIn, _ := req.Context().Value(nonIdempotentRetryKey{}).(bool)
+	return transport.policy.RetryNonIdempotent || optedIn
+}
+
+// Returns how long to wait before the next attempt. A "Retry-After" header always wins over the exponential backoff.
+func (transport *retryTransport) backoff (attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if delay, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			return delay
+		}
+	}
+
+	delay := transport.policy.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > transport.policy.MaxDelay {
+		delay = transport.policy.MaxDelay
+	}
+
+	jitterMutex.Lock()
+	defer jitterMutex.Unlock()
+	return delay/2 + time.Duration(jitterSource.Int63n(int64(delay/2)+1))
+}
+
+// Reads a "Retry-After" header, which can either be a number of seconds or a date.
+func parseRetryAfter (header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// Checks if a response is worth another attempt: network errors, 429, 5xx and the 403 that the API sends when a rate limit is exceeded.
+func shouldRetry (res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusForbidden:
+		return isRateLimitError(res)
+	}
+
+	return false
+}
+
+// Checks if a 403 response was caused by a rate limit. The body is read and put back, so the caller can still read it.
+func isRateLimitError (res *http.Response) bool {
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	var reply struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return false
+	}
+
+	for _, item := range reply.Error.Errors {
+		if item.Reason == "userRateLimitExceeded" || item.Reason == "rateLimitExceeded" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ===================================== Token Storage =====================================
+
+// Kinds of token stores that can be chosen in the configuration.
+const (
+	fileTokenStoreKind          = "file"
+	encryptedFileTokenStoreKind = "encrypted-file"
+	envTokenStoreKind           = "env"
+)
+
+// The environment variable read by the "env" token store.
+const tokenVariable = "GOOGLE_OAUTH_TOKEN"
+
+// Returned by a token store that has no token yet. It is the only error that leads to a new sign in.
+var errTokenNotFound = errors.New("there is no token saved yet")
+
+// Returned when the stored token cannot be read. The store is never overwritten in this case, so nothing is lost by a wrong key or a bad edit.
+type corruptedTokenStoreError struct {
+	Store string
+	Err   error
+}
+
+func (err *corruptedTokenStoreError) Error () string {
+	return fmt.Sprintf("the token stored in %s is corrupted, fix it or delete it to sign in again: %v", err.Store, err.Err)
+}
+
+func (err *corruptedTokenStoreError) Unwrap () error {
+	return err.Err
+}
+
+// Where the token of the signed in user is kept between runs. Load returns "errTokenNotFound" when there is no token yet, and a "corruptedTokenStoreError" when the token cannot be read.
+type tokenStore interface {
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+}
+
+// Decodes a stored token, checking that it can still be used.
+func decodeToken (data []byte, store string) (*oauth2.Token, error) {
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, &corruptedTokenStoreError{Store: store, Err: err}
+	}
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		return nil, &corruptedTokenStoreError{Store: store, Err: errors.New("the token has neither an access token nor a refresh token")}
+	}
+
+	return token, nil
+}
+
+// Writes a file only readable by its owner. The data is written to a temporary file first, so a crash never leaves a broken token behind.
+func writePrivateFile (path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	temporaryPath := path + ".tmp"
+	if err := ioutil.WriteFile(temporaryPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, path)
+}
+
+// Keeps the token as JSON in a file, like "credentials/token.json".
+type fileTokenStore struct {
+	Path string
+}
+
+func (store *fileTokenStore) Load () (*oauth2.Token, error) {
+	data, err := ioutil.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return nil, errTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeToken(data, store.Path)
+}
+
+func (store *fileTokenStore) Save (token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return writePrivateFile(store.Path, data)
+}
+
+// Keeps the token in a file encrypted with AES-GCM, so a copy of the file is useless without the key. The key must have 32 bytes.
+type encryptedFileTokenStore struct {
+	Path string
+	Key  []byte
+}
+
+// This function creates an encrypted token store. You have to provide the path of the file and the key, encoded in base64. A key can be created with "openssl rand -base64 32".
+func newEncryptedFileTokenStore (path string, encodedKey string) (*encryptedFileTokenStore, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("the token key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the token key must have 32 bytes, it has %d", len(key))
+	}
+
+	return &encryptedFileTokenStore{Path: path, Key: key}, nil
+}
+
+func (store *encryptedFileTokenStore) newCipher () (cipher.AEAD, error) {
+	block, err := aes.NewCipher(store.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (store *encryptedFileTokenStore) Load () (*oauth2.Token, error) {
+	data, err := ioutil.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return nil, errTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := store.newCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, &corruptedTokenStoreError{Store: store.Path, Err: errors.New("the file is too short")}
+	}
+
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, &corruptedTokenStoreError{Store: store.Path, Err: errors.New("the file cannot be decrypted, the key may be wrong")}
+	}
+
+	return decodeToken(plain, store.Path)
+}
+
+func (store *encryptedFileTokenStore) Save (token *oauth2.Token) error {
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	aead, err := store.newCipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	return writePrivateFile(store.Path, aead.Seal(nonce, nonce, plain, nil))
+}
+
+// Reads the token as JSON from an environment variable, which fits containers and CI secrets.
+//
+// Please note that a saved token only lives until the program exits, the variable itself cannot be changed from here. Since the refresh token does not change, the stored one keeps working.
+type envTokenStore struct {
+	Variable string
+}
+
+func (store *envTokenStore) Load () (*oauth2.Token, error) {
+	value := os.Getenv(store.Variable)
+	if value == "" {
+		return nil, errTokenNotFound
+	}
+
+	return decodeToken([]byte(value), "the "+store.Variable+" variable")
+}
+
+func (store *envTokenStore) Save (token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return os.Setenv(store.Variable, string(data))
+}
+
+// This function returns the token store chosen in the authentication settings. The default is the plain file.
+func newTokenStore (config authConfig) (tokenStore, error) {
+	switch config.TokenStore {
+	case "", fileTokenStoreKind:
+		return &fileTokenStore{Path: config.TokenFile}, nil
+	case encryptedFileTokenStoreKind:
+		return newEncryptedFileTokenStore(config.TokenFile, config.TokenKey)
+	case envTokenStoreKind:
+		return &envTokenStore{Variable: tokenVariable}, nil
+	}
+
+	return nil, fmt.Errorf("unknown token store %q, use %q, %q or %q", config.TokenStore, fileTokenStoreKind, encryptedFileTokenStoreKind, envTokenStoreKind)
+}
+
+// A token source that saves every new token in a store, so the tokens refreshed while the program runs are not lost when it exits.
+type persistingTokenSource struct {
+	mutex sync.Mutex
+	base  oauth2.TokenSource
+	store tokenStore
+	last  *oauth2.Token
+}
+
+// This function wraps a token source, saving in the store every token it gives that is different from the last one. You have to provide the token source, the store and the token already stored.
+func newPersistingTokenSource (base oauth2.TokenSource, store tokenStore, stored *oauth2.Token) *persistingTokenSource {
+	return &persistingTokenSource{base: base, store: store, last: stored}
+}
+
+// Returns a valid token. A failure to save the token is printed, but the token is still returned, so the request goes on.
+func (source *persistingTokenSource) Token () (*oauth2.Token, error) {
+	token, err := source.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if source.last == nil || token.AccessToken != source.last.AccessToken {
+		if err := source.store.Save(token); err != nil {
+			errorPrinter(fmt.Errorf("unable to save the refreshed token: %w", err))
+		}
+		source.last = token
+	}
+
+	return token, nil
+}